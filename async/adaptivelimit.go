@@ -0,0 +1,429 @@
+package async
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAdaptiveInitialLimit = 20
+	defaultAdaptiveMaxLimit     = 1000
+	defaultAIMDBackoff          = 0.9
+	defaultGradientTolerance    = 1.5
+	defaultGradientSmoothing    = 0.2
+	defaultGradientWindow       = 100
+)
+
+// LimitSample is the outcome of one call made under an AdaptiveLimiter, as
+// passed to its LimitAlgorithm.
+type LimitSample struct {
+	// RTT is the time between acquiring the token and marking it.
+	RTT time.Duration
+	// InFlight is the number of calls in flight when the token was acquired,
+	// including this one. Algorithms use it to avoid growing a limit that the
+	// load does not reach.
+	InFlight int
+	// Dropped reports that the call failed or timed out, which signals overload.
+	Dropped bool
+}
+
+// LimitAlgorithm computes the concurrency limit of an AdaptiveLimiter from the
+// samples it observes. Update is called with the current limit after every
+// call marked as a success or a drop and returns the new limit, which the
+// limiter then bounds to its MinLimit and MaxLimit. The limiter serializes the
+// calls to Update, so algorithms may keep state without locking.
+type LimitAlgorithm interface {
+	Update(limit int, sample LimitSample) int
+}
+
+// AIMDConfig configures the algorithm returned by NewAIMD. Zero fields select defaults.
+type AIMDConfig struct {
+	// Increase is added to the limit after every successful call made while at
+	// least half of the limit was in use. Defaults to 1.
+	Increase int
+	// Backoff is the factor the limit is multiplied by after a dropped call,
+	// between 0 and 1. Defaults to 0.9.
+	Backoff float64
+	// Timeout makes successful calls slower than it count as dropped.
+	// Zero means calls are only dropped when they are marked so.
+	Timeout time.Duration
+}
+
+// NewAIMD returns an additive-increase/multiplicative-decrease LimitAlgorithm:
+// the limit grows by a constant while calls succeed and shrinks by a factor when
+// one is dropped. It reacts to errors and timeouts only, not to latency, which
+// suits dependencies that fail fast when overloaded.
+//
+// Example:
+//
+//	limiter := async.NewAdaptiveLimiter(async.AdaptiveLimiterConfig{
+//		Algorithm: async.NewAIMD(async.AIMDConfig{Backoff: 0.75, Timeout: time.Second}),
+//	})
+func NewAIMD(cfg AIMDConfig) LimitAlgorithm {
+	if cfg.Increase <= 0 {
+		cfg.Increase = 1
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = defaultAIMDBackoff
+	}
+	return &aimd{cfg: cfg}
+}
+
+// aimd is the algorithm returned by NewAIMD.
+type aimd struct {
+	cfg AIMDConfig
+}
+
+func (a *aimd) Update(limit int, s LimitSample) int {
+	if s.Dropped || (a.cfg.Timeout > 0 && s.RTT > a.cfg.Timeout) {
+		return int(float64(limit) * a.cfg.Backoff)
+	}
+	if s.InFlight*2 >= limit {
+		return limit + a.cfg.Increase
+	}
+	return limit
+}
+
+// GradientConfig configures the algorithm returned by NewGradient. Zero fields
+// select defaults.
+type GradientConfig struct {
+	// Tolerance is how many times slower than their long-term average calls may
+	// get before the limit is reduced. Defaults to 1.5.
+	Tolerance float64
+	// Smoothing is the weight, between 0 and 1, of each new estimate in the
+	// limit, which damps oscillations. Defaults to 0.2.
+	Smoothing float64
+	// Window is the number of samples the long-term average latency spans.
+	// Defaults to 100.
+	Window int
+}
+
+// NewGradient returns a latency-based LimitAlgorithm in the style of TCP Vegas.
+// It compares the latency of every call with a long-term average: while calls
+// are as fast as usual, the limit grows by a queue allowance of the square root
+// of the limit; as they slow down, which happens when the dependency starts
+// queueing, the limit shrinks in proportion to the slowdown, and a dropped call
+// counts as a slowdown by half. Every change is damped by Smoothing. It finds the
+// concurrency at which latency starts to degrade, before errors appear.
+//
+// Example:
+//
+//	limiter := async.NewAdaptiveLimiter(async.AdaptiveLimiterConfig{
+//		Algorithm:    async.NewGradient(async.GradientConfig{Tolerance: 2}),
+//		InitialLimit: 10,
+//		MaxLimit:     200,
+//	})
+func NewGradient(cfg GradientConfig) LimitAlgorithm {
+	if cfg.Tolerance < 1 {
+		cfg.Tolerance = defaultGradientTolerance
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = defaultGradientSmoothing
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultGradientWindow
+	}
+	return &gradient{cfg: cfg}
+}
+
+// gradient is the algorithm returned by NewGradient.
+type gradient struct {
+	cfg      GradientConfig
+	longRTT  float64 // moving average of the latency, in nanoseconds
+	estimate float64 // unrounded limit
+}
+
+func (g *gradient) Update(limit int, s LimitSample) int {
+	if int(math.Round(g.estimate)) != limit {
+		g.estimate = float64(limit)
+	}
+
+	grad := 1.0
+	if s.Dropped {
+		grad = 0.5
+	} else if rtt := float64(s.RTT); rtt > 0 {
+		if g.longRTT == 0 {
+			g.longRTT = rtt
+		} else {
+			g.longRTT += (rtt - g.longRTT) / float64(g.cfg.Window)
+		}
+		// Once latency has dropped well below the average, for example after an
+		// incident, let the average catch up faster than the window allows.
+		if g.longRTT > 2*rtt {
+			g.longRTT *= 0.9
+		}
+		grad = math.Max(0.5, math.Min(1, g.cfg.Tolerance*g.longRTT/rtt))
+
+		// Do not grow a limit that the load does not use.
+		if grad == 1 && s.InFlight*2 < limit {
+			return limit
+		}
+	}
+
+	target := g.estimate*grad + math.Sqrt(g.estimate)
+	if grad < 1 {
+		target = g.estimate * grad
+	}
+	g.estimate += (target - g.estimate) * g.cfg.Smoothing
+	return int(math.Round(g.estimate))
+}
+
+// AdaptiveLimiterConfig configures an AdaptiveLimiter. Zero fields select defaults.
+type AdaptiveLimiterConfig struct {
+	// Algorithm computes the limit from the observed calls. Defaults to
+	// NewAIMD(AIMDConfig{}). An algorithm keeps state and must not be shared
+	// between limiters.
+	Algorithm LimitAlgorithm
+	// InitialLimit is the limit before any call has been observed. Defaults to 20.
+	InitialLimit int
+	// MinLimit is the lowest limit the algorithm may set. Defaults to 1.
+	MinLimit int
+	// MaxLimit is the highest limit the algorithm may set. Defaults to 1000.
+	MaxLimit int
+	// OnLimitChange, if set, is called after every change of the limit, for
+	// example to export it as a metric.
+	OnLimitChange func(from, to int)
+	// Clock is the clock the latency of calls is measured on. Defaults to the
+	// system clock.
+	Clock Clock
+}
+
+// AdaptiveLimiter bounds the number of calls in flight to a dependency with a
+// limit that adjusts itself to the latency and errors it observes, instead of a
+// static cap that is too low when the dependency is healthy and too high when
+// it struggles. Callers Acquire a token before the call and mark it with its
+// outcome afterwards. It is safe for concurrent use.
+//
+// The limiter can also bound a Pool or the Parallel helpers, see WithAdaptiveLimiter.
+//
+// Example:
+//
+//	limiter := async.NewAdaptiveLimiter(async.AdaptiveLimiterConfig{
+//		Algorithm: async.NewGradient(async.GradientConfig{}),
+//	})
+//
+//	token, err := limiter.Acquire(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	resp, err := client.Do(req)
+//	switch {
+//	case err == nil:
+//		token.Success()
+//	case errors.Is(err, context.Canceled):
+//		token.Ignore() // the caller gave up, not a sign of overload
+//	default:
+//		token.Dropped()
+//	}
+type AdaptiveLimiter struct {
+	cfg AdaptiveLimiterConfig
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	waiters  list.List // of *limitWaiter
+}
+
+// limitWaiter is a blocked Acquire call, granted its token by notify.
+type limitWaiter struct {
+	token *LimitToken
+	ready chan struct{}
+}
+
+// NewAdaptiveLimiter returns an AdaptiveLimiter starting at the configured initial limit.
+func NewAdaptiveLimiter(cfg AdaptiveLimiterConfig) *AdaptiveLimiter {
+	if cfg.Algorithm == nil {
+		cfg.Algorithm = NewAIMD(AIMDConfig{})
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = defaultAdaptiveMaxLimit
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = defaultAdaptiveInitialLimit
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+
+	l := &AdaptiveLimiter{cfg: cfg}
+	l.limit = l.bound(cfg.InitialLimit)
+	return l
+}
+
+// Acquire blocks until a call may start without exceeding the limit, or until
+// ctx is done, in which case it returns ctx.Err(). Callers are served in order.
+// The returned token must be marked with Success, Dropped or Ignore once the
+// call has completed.
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (*LimitToken, error) {
+	l.mu.Lock()
+	if l.inFlight < l.limit && l.waiters.Len() == 0 {
+		t := l.grant()
+		l.mu.Unlock()
+		return t, nil
+	}
+
+	w := &limitWaiter{ready: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.token, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	select {
+	case <-w.ready:
+		// Granted while ctx was being cancelled: give the slot back.
+		l.mu.Unlock()
+		w.token.Ignore()
+	default:
+		l.waiters.Remove(elem)
+		l.mu.Unlock()
+	}
+	return nil, ctx.Err()
+}
+
+// TryAcquire returns a token if a call may start right away, and reports whether it did.
+func (l *AdaptiveLimiter) TryAcquire() (*LimitToken, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight < l.limit && l.waiters.Len() == 0 {
+		return l.grant(), true
+	}
+	return nil, false
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of tokens acquired and not yet marked.
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// grant takes a slot and returns its token. l.mu must be held.
+func (l *AdaptiveLimiter) grant() *LimitToken {
+	l.inFlight++
+	return &LimitToken{limiter: l, start: l.cfg.Clock.Now(), inFlight: l.inFlight}
+}
+
+// release frees the slot of t and, unless the outcome is ignored, feeds the
+// sample to the algorithm.
+func (l *AdaptiveLimiter) release(t *LimitToken, outcome limitOutcome) {
+	l.mu.Lock()
+	if t.released {
+		l.mu.Unlock()
+		return
+	}
+	t.released = true
+	l.inFlight--
+
+	from := l.limit
+	if outcome != limitIgnored {
+		l.limit = l.bound(l.cfg.Algorithm.Update(l.limit, LimitSample{
+			RTT:      l.cfg.Clock.Now().Sub(t.start),
+			InFlight: t.inFlight,
+			Dropped:  outcome == limitDropped,
+		}))
+	}
+	to := l.limit
+	l.notify()
+	l.mu.Unlock()
+
+	if from != to && l.cfg.OnLimitChange != nil {
+		l.cfg.OnLimitChange(from, to)
+	}
+}
+
+// notify grants slots to the waiters at the head of the queue. l.mu must be held.
+func (l *AdaptiveLimiter) notify() {
+	for l.inFlight < l.limit {
+		front := l.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*limitWaiter)
+		l.waiters.Remove(front)
+		w.token = l.grant()
+		close(w.ready)
+	}
+}
+
+// bound limits n to the configured minimum and maximum.
+func (l *AdaptiveLimiter) bound(n int) int {
+	if n < l.cfg.MinLimit {
+		return l.cfg.MinLimit
+	}
+	if n > l.cfg.MaxLimit {
+		return l.cfg.MaxLimit
+	}
+	return n
+}
+
+// limitOutcome is how a LimitToken was marked.
+type limitOutcome int
+
+const (
+	limitSucceeded limitOutcome = iota
+	limitDropped
+	limitIgnored
+)
+
+// LimitToken is a slot acquired from an AdaptiveLimiter. Marking it releases the
+// slot; only the first mark counts.
+type LimitToken struct {
+	limiter  *AdaptiveLimiter
+	start    time.Time
+	inFlight int
+	released bool // guarded by limiter.mu
+}
+
+// Success marks the call as successful, feeding its latency to the limiter.
+func (t *LimitToken) Success() {
+	t.limiter.release(t, limitSucceeded)
+}
+
+// Dropped marks the call as failed because of overload, such as a timeout or a
+// rejection by the dependency, which makes the limiter lower its limit.
+func (t *LimitToken) Dropped() {
+	t.limiter.release(t, limitDropped)
+}
+
+// Ignore releases the slot without feeding the call to the limiter, for calls
+// whose outcome says nothing about the dependency, such as those cancelled by
+// the caller or failing validation.
+func (t *LimitToken) Ignore() {
+	t.limiter.release(t, limitIgnored)
+}
+
+// markToken marks t with the outcome of a call that returned err: nil is a
+// success, a cancellation is ignored, and any other error is a drop.
+func markToken(t *LimitToken, err error) {
+	switch {
+	case err == nil:
+		t.Success()
+	case errors.Is(err, context.Canceled):
+		t.Ignore()
+	default:
+		t.Dropped()
+	}
+}
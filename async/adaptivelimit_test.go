@@ -0,0 +1,245 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	alg := NewAIMD(AIMDConfig{Increase: 2, Backoff: 0.5, Timeout: time.Second})
+
+	tests := []struct {
+		name   string
+		limit  int
+		sample LimitSample
+		want   int
+	}{
+		{"grows under load", 10, LimitSample{RTT: time.Millisecond, InFlight: 5}, 12},
+		{"holds when the limit is not used", 10, LimitSample{RTT: time.Millisecond, InFlight: 4}, 10},
+		{"backs off on drops", 10, LimitSample{RTT: time.Millisecond, InFlight: 10, Dropped: true}, 5},
+		{"treats slow calls as drops", 10, LimitSample{RTT: 2 * time.Second, InFlight: 10}, 5},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alg.Update(tt.limit, tt.sample); got != tt.want {
+				t.Errorf("Update(%d, %+v) = %d, want %d", tt.limit, tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradient(t *testing.T) {
+	t.Run("grows while latency is steady", func(t *testing.T) {
+		alg := NewGradient(GradientConfig{})
+		limit := 10
+		for i := 0; i < 20; i++ {
+			limit = alg.Update(limit, LimitSample{RTT: 10 * time.Millisecond, InFlight: limit})
+		}
+		if limit <= 10 {
+			t.Errorf("limit = %d after steady samples, want it to grow", limit)
+		}
+	})
+
+	t.Run("shrinks when latency degrades", func(t *testing.T) {
+		alg := NewGradient(GradientConfig{})
+		limit := 50
+		for i := 0; i < 10; i++ {
+			limit = alg.Update(limit, LimitSample{RTT: 10 * time.Millisecond, InFlight: limit})
+		}
+		peak := limit
+		for i := 0; i < 10; i++ {
+			limit = alg.Update(limit, LimitSample{RTT: 50 * time.Millisecond, InFlight: limit})
+		}
+		if limit >= peak {
+			t.Errorf("limit = %d after slow samples, want it below %d", limit, peak)
+		}
+	})
+
+	t.Run("shrinks on drops", func(t *testing.T) {
+		alg := NewGradient(GradientConfig{Smoothing: 1})
+		if got := alg.Update(40, LimitSample{InFlight: 40, Dropped: true}); got != 20 {
+			t.Errorf("Update() after a drop = %d, want 20", got)
+		}
+	})
+
+	t.Run("holds when the limit is not used", func(t *testing.T) {
+		alg := NewGradient(GradientConfig{})
+		if got := alg.Update(40, LimitSample{RTT: time.Millisecond, InFlight: 2}); got != 40 {
+			t.Errorf("Update() = %d, want 40", got)
+		}
+	})
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	t.Run("blocks at the limit and serves waiters in order", func(t *testing.T) {
+		l := NewAdaptiveLimiter(AdaptiveLimiterConfig{InitialLimit: 1, MaxLimit: 1})
+		first, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := l.TryAcquire(); ok {
+			t.Fatal("TryAcquire() succeeded at the limit")
+		}
+
+		order := make(chan int, 2)
+		var wg sync.WaitGroup
+		for i := 1; i <= 2; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := l.Acquire(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				order <- i
+				token.Success()
+			}()
+			// Let the goroutine queue before starting the next one.
+			for queued := 0; queued < i; {
+				time.Sleep(time.Millisecond)
+				l.mu.Lock()
+				queued = l.waiters.Len()
+				l.mu.Unlock()
+			}
+		}
+
+		first.Success()
+		wg.Wait()
+		if a, b := <-order, <-order; a != 1 || b != 2 {
+			t.Errorf("waiters served in order %d, %d, want 1, 2", a, b)
+		}
+		if n := l.InFlight(); n != 0 {
+			t.Errorf("InFlight() = %d, want 0", n)
+		}
+	})
+
+	t.Run("Acquire stops on cancellation", func(t *testing.T) {
+		l := NewAdaptiveLimiter(AdaptiveLimiterConfig{InitialLimit: 1, MaxLimit: 1})
+		token, _ := l.Acquire(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Acquire() error = %v, want DeadlineExceeded", err)
+		}
+
+		token.Success()
+		if _, ok := l.TryAcquire(); !ok {
+			t.Error("TryAcquire() failed after the cancelled waiter left")
+		}
+	})
+
+	t.Run("adapts the limit to outcomes", func(t *testing.T) {
+		var changes [][2]int
+		l := NewAdaptiveLimiter(AdaptiveLimiterConfig{
+			Algorithm:     NewAIMD(AIMDConfig{Backoff: 0.5}),
+			InitialLimit:  4,
+			MinLimit:      2,
+			MaxLimit:      5,
+			OnLimitChange: func(from, to int) { changes = append(changes, [2]int{from, to}) },
+		})
+
+		tokens := make([]*LimitToken, 4)
+		for i := range tokens {
+			tokens[i], _ = l.TryAcquire()
+		}
+		tokens[0].Success()
+		tokens[0].Dropped() // only the first mark counts
+		tokens[1].Success() // capped at MaxLimit
+		tokens[2].Ignore()
+		if got := l.Limit(); got != 5 {
+			t.Fatalf("Limit() = %d, want 5", got)
+		}
+		tokens[3].Dropped()
+		if got := l.Limit(); got != 2 {
+			t.Errorf("Limit() = %d after a drop, want 2", got)
+		}
+		if len(changes) != 2 || changes[0] != [2]int{4, 5} || changes[1] != [2]int{5, 2} {
+			t.Errorf("limit changes = %v, want [[4 5] [5 2]]", changes)
+		}
+	})
+
+	t.Run("measures latency on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		l := NewAdaptiveLimiter(AdaptiveLimiterConfig{
+			Algorithm:    NewAIMD(AIMDConfig{Timeout: time.Second}),
+			InitialLimit: 10,
+			Clock:        clock,
+		})
+		token, _ := l.TryAcquire()
+		clock.Advance(2 * time.Second)
+		token.Success()
+		if got := l.Limit(); got != 9 {
+			t.Errorf("Limit() = %d after a slow call, want 9", got)
+		}
+	})
+}
+
+func TestWithAdaptiveLimiter(t *testing.T) {
+	newLimiter := func() *AdaptiveLimiter {
+		return NewAdaptiveLimiter(AdaptiveLimiterConfig{InitialLimit: 3, MaxLimit: 3})
+	}
+	track := func(running, peak *int64) {
+		n := atomic.AddInt64(running, 1)
+		for {
+			p := atomic.LoadInt64(peak)
+			if n <= p || atomic.CompareAndSwapInt64(peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(running, -1)
+	}
+
+	t.Run("bounds parallel helpers", func(t *testing.T) {
+		l := newLimiter()
+		var running, peak int64
+		errBad := errors.New("bad")
+		_, err := ParallelMapCtx(context.Background(), make([]int, 50), func(_ context.Context, i int) (int, error) {
+			track(&running, &peak)
+			return i, nil
+		}, WithAdaptiveLimiter(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if peak > 3 {
+			t.Errorf("peak concurrency = %d, want at most 3", peak)
+		}
+
+		err = ParallelForEach(context.Background(), []int{1}, func(context.Context, int) error {
+			return errBad
+		}, WithAdaptiveLimiter(l))
+		if !errors.Is(err, errBad) {
+			t.Errorf("ParallelForEach() error = %v, want %v", err, errBad)
+		}
+		if got := l.Limit(); got != 2 {
+			t.Errorf("Limit() = %d after a failed task, want 2", got)
+		}
+		if n := l.InFlight(); n != 0 {
+			t.Errorf("InFlight() = %d, want 0", n)
+		}
+	})
+
+	t.Run("bounds pools", func(t *testing.T) {
+		l := newLimiter()
+		var running, peak int64
+		pool := NewPool(8, WithAdaptiveLimiter(l))
+		for i := 0; i < 50; i++ {
+			pool.Submit(func() { track(&running, &peak) })
+		}
+		pool.Close()
+		if peak > 3 {
+			t.Errorf("peak concurrency = %d, want at most 3", peak)
+		}
+		if n := l.InFlight(); n != 0 {
+			t.Errorf("InFlight() = %d, want 0", n)
+		}
+	})
+}
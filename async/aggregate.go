@@ -0,0 +1,286 @@
+package async
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultWindowBuckets is the number of buckets a WindowCounter or a BucketedSum
+// keeps when none is given.
+const defaultWindowBuckets = 10
+
+// WindowCounter counts events over a sliding time window, such as the requests
+// of the last minute, to compute live throughput without a metrics backend.
+// The window is split into buckets that expire one at a time, so the count
+// covers between window-window/buckets and window of history; more buckets
+// make it smoother at the cost of memory. It is safe for concurrent use.
+//
+// Example:
+//
+//	requests := async.NewWindowCounter(time.Minute, 60)
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		requests.Inc()
+//		// ...
+//	}
+//
+//	log.Printf("%d requests in the last minute, %.1f/s", requests.Count(), requests.Rate())
+type WindowCounter struct {
+	window time.Duration
+	ring   *bucketRing[int64]
+}
+
+// NewWindowCounter returns a WindowCounter over window split into buckets,
+// measured on the clock set with WithClock. Values less than or equal to zero
+// select a window of one minute and 10 buckets.
+func NewWindowCounter(window time.Duration, buckets int, opts ...Option) *WindowCounter {
+	if window <= 0 {
+		window = time.Minute
+	}
+	if buckets <= 0 {
+		buckets = defaultWindowBuckets
+	}
+	return &WindowCounter{
+		window: window,
+		ring:   newBucketRing[int64](window/time.Duration(buckets), buckets, applyOptions(opts).clock),
+	}
+}
+
+// Add counts n events now.
+func (c *WindowCounter) Add(n int64) {
+	c.ring.add(n)
+}
+
+// Inc counts one event now.
+func (c *WindowCounter) Inc() {
+	c.ring.add(1)
+}
+
+// Count returns the number of events in the window.
+func (c *WindowCounter) Count() int64 {
+	var total int64
+	for _, b := range c.ring.snapshot() {
+		total += b.value
+	}
+	return total
+}
+
+// Rate returns the average number of events per second over the window.
+func (c *WindowCounter) Rate() float64 {
+	return float64(c.Count()) / c.window.Seconds()
+}
+
+// TimeBucket is the sum of the values added during one time bucket of a BucketedSum.
+type TimeBucket struct {
+	// Start is the beginning of the bucket, a multiple of the bucket width.
+	Start time.Time
+	// Sum is the sum of the values added during the bucket.
+	Sum float64
+}
+
+// BucketedSum sums values into fixed time buckets aligned on multiples of
+// their width, such as the bytes sent in each of the last 60 seconds, and keeps
+// the most recent buckets. It is safe for concurrent use.
+//
+// Example:
+//
+//	sent := async.NewBucketedSum(time.Second, 60)
+//	sent.Add(float64(n))
+//
+//	for _, b := range sent.Buckets() {
+//		fmt.Printf("%s %8.0f B/s\n", b.Start.Format(time.TimeOnly), b.Sum)
+//	}
+type BucketedSum struct {
+	ring *bucketRing[float64]
+}
+
+// NewBucketedSum returns a BucketedSum keeping count buckets of the given width,
+// measured on the clock set with WithClock. Values less than or equal to zero
+// select buckets of one second and 10 buckets.
+func NewBucketedSum(width time.Duration, count int, opts ...Option) *BucketedSum {
+	if width <= 0 {
+		width = time.Second
+	}
+	if count <= 0 {
+		count = defaultWindowBuckets
+	}
+	return &BucketedSum{ring: newBucketRing[float64](width, count, applyOptions(opts).clock)}
+}
+
+// Add adds v to the current bucket.
+func (s *BucketedSum) Add(v float64) {
+	s.ring.add(v)
+}
+
+// Sum returns the sum of the values in all the buckets kept.
+func (s *BucketedSum) Sum() float64 {
+	var total float64
+	for _, b := range s.ring.snapshot() {
+		total += b.value
+	}
+	return total
+}
+
+// Buckets returns the buckets kept, oldest first, including empty ones. The
+// last one is the current bucket, which is still filling up.
+func (s *BucketedSum) Buckets() []TimeBucket {
+	snapshot := s.ring.snapshot()
+	buckets := make([]TimeBucket, len(snapshot))
+	for i, b := range snapshot {
+		buckets[i] = TimeBucket{Start: b.start, Sum: b.value}
+	}
+	return buckets
+}
+
+// EWMARate is an exponentially weighted moving average of the rate of events
+// per second, like the load averages of Unix: recent events weigh more, and
+// the weight of an event decays by a factor e every window. Unlike a
+// WindowCounter it needs constant memory and changes smoothly, but it starts
+// from zero and takes about one window to reflect a steady rate. It is safe
+// for concurrent use.
+//
+// Example:
+//
+//	errorRate := async.NewEWMARate(time.Minute)
+//	if err != nil {
+//		errorRate.Add(1)
+//	}
+//
+//	if errorRate.Rate() > 10 {
+//		alert("more than 10 errors per second")
+//	}
+type EWMARate struct {
+	clock  Clock
+	window float64 // in seconds
+
+	mu    sync.Mutex
+	value float64 // decayed sum of the events up to last
+	last  time.Time
+}
+
+// NewEWMARate returns an EWMARate averaging over window, measured on the clock
+// set with WithClock. Values less than or equal to zero select one minute.
+func NewEWMARate(window time.Duration, opts ...Option) *EWMARate {
+	if window <= 0 {
+		window = time.Minute
+	}
+	clock := applyOptions(opts).clock
+	return &EWMARate{clock: clock, window: window.Seconds(), last: clock.Now()}
+}
+
+// Add records n events now.
+func (r *EWMARate) Add(n float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	r.value = r.decayed(now) + n
+	r.last = now
+}
+
+// Rate returns the current average number of events per second.
+func (r *EWMARate) Rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.decayed(r.clock.Now()) / r.window
+}
+
+// decayed returns the sum of the events decayed up to now. r.mu must be held.
+func (r *EWMARate) decayed(now time.Time) float64 {
+	elapsed := now.Sub(r.last).Seconds()
+	if elapsed <= 0 {
+		return r.value
+	}
+	return r.value * math.Exp(-elapsed/r.window)
+}
+
+// bucketRing keeps the values of the most recent time buckets of a fixed width
+// in a ring, clearing each slot lazily when its bucket comes around again.
+type bucketRing[N int64 | float64] struct {
+	clock  Clock
+	width  time.Duration
+	origin time.Time // start of bucket 0, a multiple of width since the zero time
+
+	mu      sync.Mutex
+	values  []N
+	buckets []int64 // bucket number held by each slot, noBucket if none
+}
+
+// noBucket marks a slot of a bucketRing that holds no bucket yet.
+const noBucket = math.MinInt64
+
+// bucketValue is the value of one bucket in a snapshot of a bucketRing.
+type bucketValue[N int64 | float64] struct {
+	start time.Time
+	value N
+}
+
+// newBucketRing returns a ring of count buckets of the given width, at least
+// one nanosecond.
+func newBucketRing[N int64 | float64](width time.Duration, count int, clock Clock) *bucketRing[N] {
+	if width <= 0 {
+		width = 1
+	}
+	r := &bucketRing[N]{
+		clock:   clock,
+		width:   width,
+		origin:  clock.Now().Truncate(width),
+		values:  make([]N, count),
+		buckets: make([]int64, count),
+	}
+	for i := range r.buckets {
+		r.buckets[i] = noBucket
+	}
+	return r
+}
+
+// add adds v to the current bucket.
+func (r *bucketRing[N]) add(v N) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bucket(r.clock.Now())
+	i := r.slot(b)
+	if r.buckets[i] != b {
+		r.buckets[i] = b
+		r.values[i] = 0
+	}
+	r.values[i] += v
+}
+
+// snapshot returns the buckets of the ring, oldest first and ending with the
+// current one.
+func (r *bucketRing[N]) snapshot() []bucketValue[N] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := int64(len(r.values))
+	current := r.bucket(r.clock.Now())
+	snapshot := make([]bucketValue[N], n)
+	for k := range snapshot {
+		b := current - n + 1 + int64(k)
+		snapshot[k].start = r.origin.Add(time.Duration(b) * r.width)
+		if i := r.slot(b); r.buckets[i] == b {
+			snapshot[k].value = r.values[i]
+		}
+	}
+	return snapshot
+}
+
+// bucket returns the number of the bucket t falls in, counted from the origin
+// and negative before it.
+func (r *bucketRing[N]) bucket(t time.Time) int64 {
+	d := t.Sub(r.origin)
+	b := int64(d / r.width)
+	if d%r.width < 0 {
+		b-- // round towards minus infinity
+	}
+	return b
+}
+
+// slot returns the index of the slot holding bucket b.
+func (r *bucketRing[N]) slot(b int64) int64 {
+	n := int64(len(r.values))
+	return (b%n + n) % n
+}
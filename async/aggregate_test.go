@@ -0,0 +1,108 @@
+package async
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := NewWindowCounter(10*time.Second, 10, WithClock(clock))
+
+	c.Add(5)
+	clock.Advance(3 * time.Second)
+	c.Inc()
+	if got := c.Count(); got != 6 {
+		t.Errorf("Count() = %d, want 6", got)
+	}
+	if got := c.Rate(); got != 0.6 {
+		t.Errorf("Rate() = %v, want 0.6", got)
+	}
+
+	clock.Advance(7 * time.Second) // the first bucket has left the window
+	if got := c.Count(); got != 1 {
+		t.Errorf("Count() = %d, want 1 after the first bucket expired", got)
+	}
+	clock.Advance(time.Hour)
+	if got := c.Count(); got != 0 {
+		t.Errorf("Count() = %d, want 0 after an idle hour", got)
+	}
+	c.Inc()
+	if got := c.Count(); got != 1 {
+		t.Errorf("Count() = %d, want 1", got)
+	}
+}
+
+func TestBucketedSum(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0).Add(500 * time.Millisecond))
+	s := NewBucketedSum(time.Second, 3, WithClock(clock))
+
+	s.Add(1.5)
+	s.Add(1)
+	clock.Advance(2 * time.Second)
+	s.Add(4)
+
+	buckets := s.Buckets()
+	want := []TimeBucket{
+		{Start: time.Unix(1000, 0), Sum: 2.5},
+		{Start: time.Unix(1001, 0), Sum: 0},
+		{Start: time.Unix(1002, 0), Sum: 4},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("Buckets() = %v, want %v", buckets, want)
+	}
+	for i := range want {
+		if !buckets[i].Start.Equal(want[i].Start) || buckets[i].Sum != want[i].Sum {
+			t.Errorf("Buckets()[%d] = %+v, want %+v", i, buckets[i], want[i])
+		}
+	}
+	if got := s.Sum(); got != 6.5 {
+		t.Errorf("Sum() = %v, want 6.5", got)
+	}
+
+	clock.Advance(time.Second)
+	if got := s.Sum(); got != 4 {
+		t.Errorf("Sum() = %v, want 4 once the oldest bucket is dropped", got)
+	}
+}
+
+func TestBucketRingBeforeEpoch(t *testing.T) {
+	// Times before 1970 and a clock at the zero time must not break bucketing.
+	for _, start := range []time.Time{{}, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)} {
+		clock := NewFakeClock(start)
+		s := NewBucketedSum(time.Second, 3, WithClock(clock))
+		s.Add(1)
+		clock.Advance(1500 * time.Millisecond)
+		s.Add(2)
+
+		buckets := s.Buckets()
+		if got := buckets[2].Start; !got.Equal(start.Add(time.Second)) {
+			t.Errorf("current bucket starts at %v, want %v", got, start.Add(time.Second))
+		}
+		if buckets[1].Sum != 1 || buckets[2].Sum != 2 || s.Sum() != 3 {
+			t.Errorf("Buckets() = %+v, want sums 1 and 2 in the last two buckets", buckets)
+		}
+	}
+}
+
+func TestEWMARate(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	r := NewEWMARate(time.Minute, WithClock(clock))
+
+	// A steady 10 events per second converges to a rate of 10.
+	for i := 0; i < 6000; i++ {
+		r.Add(1)
+		clock.Advance(100 * time.Millisecond)
+	}
+	if got := r.Rate(); math.Abs(got-10) > 0.5 {
+		t.Errorf("Rate() = %v after 10 steady minutes, want about 10", got)
+	}
+
+	// Without events, the rate decays by a factor e every window.
+	before := r.Rate()
+	clock.Advance(time.Minute)
+	if got, want := r.Rate(), before/math.E; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Rate() = %v after an idle window, want %v", got, want)
+	}
+}
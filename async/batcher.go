@@ -0,0 +1,222 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultBatchWait is how long a Batcher collects keys when no Wait is configured.
+const defaultBatchWait = time.Millisecond
+
+// BatcherConfig configures a Batcher. Zero fields select defaults.
+type BatcherConfig struct {
+	// MaxBatchSize dispatches a batch as soon as it holds that many keys.
+	// Zero or less means no limit.
+	MaxBatchSize int
+	// Wait is how long a batch collects keys after its first one before it is
+	// dispatched. Defaults to 1ms.
+	Wait time.Duration
+	// Cache keeps the result of every successful load for the lifetime of the
+	// Batcher, so that later loads of the same key do not reach the batch function.
+	// Create one Batcher per request to get per-request caching.
+	Cache bool
+	// Clock is the clock Wait is measured on. Defaults to the system clock.
+	Clock Clock
+}
+
+// BatchFunc loads the values of a batch of distinct keys. It must return one
+// Result per key, in the same order as keys; a Result's error is returned to
+// the callers that loaded that key only. An error returned by the function
+// itself is returned to every caller of the batch.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]Result[V], error)
+
+// Batcher collects the keys requested by concurrent Load calls over a short
+// window and loads them with a single call to a batch function, which turns
+// one query per item into one query per batch. It is safe for concurrent use.
+//
+// Example:
+//
+//	users := async.NewBatcher(func(ctx context.Context, ids []int) ([]async.Result[User], error) {
+//		rows, err := db.UsersByID(ctx, ids) // SELECT ... WHERE id IN (...)
+//		if err != nil {
+//			return nil, err
+//		}
+//		results := make([]async.Result[User], len(ids))
+//		for i, id := range ids {
+//			if u, ok := rows[id]; ok {
+//				results[i].Value = u
+//			} else {
+//				results[i].Err = ErrUserNotFound
+//			}
+//		}
+//		return results, nil
+//	}, async.BatcherConfig{MaxBatchSize: 100, Wait: 2 * time.Millisecond})
+//
+//	// Called concurrently by many resolvers, served by a single query.
+//	author, err := users.Load(ctx, post.AuthorID)
+type Batcher[K comparable, V any] struct {
+	fn  BatchFunc[K, V]
+	cfg BatcherConfig
+
+	mu      sync.Mutex
+	pending *pendingBatch[K, V]
+	cache   map[K]*Future[V]
+}
+
+// pendingBatch is a batch that is still collecting keys.
+type pendingBatch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	futures map[K]*Future[V]
+	timer   Timer
+}
+
+// NewBatcher returns a Batcher that loads keys with fn.
+func NewBatcher[K comparable, V any](fn BatchFunc[K, V], cfg BatcherConfig) *Batcher[K, V] {
+	if cfg.Wait <= 0 {
+		cfg.Wait = defaultBatchWait
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	b := &Batcher[K, V]{fn: fn, cfg: cfg}
+	if cfg.Cache {
+		b.cache = make(map[K]*Future[V])
+	}
+	return b
+}
+
+// Load returns the value for key, adding the key to the batch being collected
+// and waiting for that batch to be loaded. Concurrent loads of the same key
+// share a single entry of the batch. If ctx is done first, Load returns ctx.Err();
+// the batch is still loaded for the other callers.
+//
+// The batch function runs with the context of the first Load of the batch,
+// detached from its cancellation so that one caller giving up does not fail the others.
+func (b *Batcher[K, V]) Load(ctx context.Context, key K) (V, error) {
+	return b.future(ctx, key).Await(ctx)
+}
+
+// LoadMany loads several keys, possibly in the same batch, and returns their
+// values and errors in the order of keys.
+//
+// Example:
+//
+//	values, errs := users.LoadMany(ctx, []int{1, 2, 3})
+func (b *Batcher[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	futures := make([]*Future[V], len(keys))
+	for i, key := range keys {
+		futures[i] = b.future(ctx, key)
+	}
+
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	for i, f := range futures {
+		values[i], errs[i] = f.Await(ctx)
+	}
+	return values, errs
+}
+
+// Clear removes key from the cache, so that its next load reaches the batch function.
+func (b *Batcher[K, V]) Clear(key K) {
+	b.mu.Lock()
+	delete(b.cache, key)
+	b.mu.Unlock()
+}
+
+// ClearAll empties the cache.
+func (b *Batcher[K, V]) ClearAll() {
+	b.mu.Lock()
+	clear(b.cache)
+	b.mu.Unlock()
+}
+
+// future returns the future of key, from the cache or the pending batch, or
+// adds key to the pending batch, starting a new batch if there is none.
+func (b *Batcher[K, V]) future(ctx context.Context, key K) *Future[V] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.cache[key]; ok {
+		return f
+	}
+
+	batch := b.pending
+	if batch == nil {
+		batch = &pendingBatch[K, V]{ctx: context.WithoutCancel(ctx), futures: make(map[K]*Future[V])}
+		b.pending = batch
+		batch.timer = b.cfg.Clock.AfterFunc(b.cfg.Wait, func() {
+			b.dispatch(batch)
+		})
+	}
+	if f, ok := batch.futures[key]; ok {
+		return f
+	}
+
+	f := newFuture[V]()
+	batch.keys = append(batch.keys, key)
+	batch.futures[key] = f
+	if b.cache != nil {
+		b.cache[key] = f
+	}
+	if b.cfg.MaxBatchSize > 0 && len(batch.keys) >= b.cfg.MaxBatchSize {
+		b.pending = nil
+		batch.timer.Stop()
+		go b.load(batch)
+	}
+	return f
+}
+
+// dispatch loads batch once its wait is over, unless it was already dispatched
+// for being full.
+func (b *Batcher[K, V]) dispatch(batch *pendingBatch[K, V]) {
+	b.mu.Lock()
+	if b.pending != batch {
+		b.mu.Unlock()
+		return
+	}
+	b.pending = nil
+	b.mu.Unlock()
+
+	b.load(batch)
+}
+
+// load calls the batch function and completes the future of every key.
+// Failed keys are removed from the cache so that they can be loaded again.
+func (b *Batcher[K, V]) load(batch *pendingBatch[K, V]) {
+	var results []Result[V]
+	err := catch(func() (err error) {
+		results, err = b.fn(batch.ctx, batch.keys)
+		return err
+	}, nil)
+	if err == nil && len(results) != len(batch.keys) {
+		err = fmt.Errorf("async: batch function returned %d results for %d keys", len(results), len(batch.keys))
+	}
+
+	var failed []K
+	for i, key := range batch.keys {
+		f := batch.futures[key]
+		if err != nil {
+			var zero V
+			f.complete(zero, err)
+			failed = append(failed, key)
+			continue
+		}
+		f.complete(results[i].Value, results[i].Err)
+		if results[i].Err != nil {
+			failed = append(failed, key)
+		}
+	}
+
+	if b.cache != nil && len(failed) > 0 {
+		b.mu.Lock()
+		for _, key := range failed {
+			if b.cache[key] == batch.futures[key] {
+				delete(b.cache, key)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
@@ -0,0 +1,169 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder is a batch function that records the batches it receives.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *batchRecorder) load(ctx context.Context, keys []int) ([]Result[string], error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]int(nil), keys...))
+	r.mu.Unlock()
+
+	results := make([]Result[string], len(keys))
+	for i, k := range keys {
+		if k < 0 {
+			results[i].Err = fmt.Errorf("negative key %d", k)
+			continue
+		}
+		results[i].Value = fmt.Sprint(k)
+	}
+	return results, nil
+}
+
+func (r *batchRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.batches)
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("collects concurrent loads into one batch", func(t *testing.T) {
+		var r batchRecorder
+		b := NewBatcher(r.load, BatcherConfig{Wait: 20 * time.Millisecond})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := b.Load(context.Background(), i%5)
+				if err != nil || v != fmt.Sprint(i%5) {
+					t.Errorf("Load(%d) = %q, %v", i%5, v, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if r.count() != 1 {
+			t.Fatalf("batch function called %d times, want 1", r.count())
+		}
+		if keys := r.batches[0]; len(keys) != 5 {
+			t.Errorf("batch keys = %v, want 5 distinct keys", keys)
+		}
+	})
+
+	t.Run("dispatches full batches", func(t *testing.T) {
+		var r batchRecorder
+		b := NewBatcher(r.load, BatcherConfig{MaxBatchSize: 3, Wait: time.Hour})
+
+		values, errs := b.LoadMany(context.Background(), []int{1, 2, 3, 4, 5, 6})
+		for i, err := range errs {
+			if err != nil || values[i] != fmt.Sprint(i+1) {
+				t.Errorf("LoadMany()[%d] = %q, %v", i, values[i], err)
+			}
+		}
+		if r.count() != 2 {
+			t.Errorf("batch function called %d times, want 2", r.count())
+		}
+	})
+
+	t.Run("per-key and batch errors", func(t *testing.T) {
+		var r batchRecorder
+		b := NewBatcher(r.load, BatcherConfig{})
+
+		_, errs := b.LoadMany(context.Background(), []int{1, -1})
+		if errs[0] != nil || errs[1] == nil {
+			t.Errorf("LoadMany() errors = %v, want an error for the negative key only", errs)
+		}
+
+		want := errors.New("db down")
+		failing := NewBatcher(func(context.Context, []int) ([]Result[string], error) {
+			return nil, want
+		}, BatcherConfig{})
+		if _, err := failing.Load(context.Background(), 1); err != want {
+			t.Errorf("Load() = %v, want %v", err, want)
+		}
+
+		short := NewBatcher(func(context.Context, []int) ([]Result[string], error) {
+			return nil, nil
+		}, BatcherConfig{})
+		if _, err := short.Load(context.Background(), 1); err == nil {
+			t.Error("Load() with missing results = nil, want error")
+		}
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		var r batchRecorder
+		b := NewBatcher(r.load, BatcherConfig{Cache: true})
+		ctx := context.Background()
+
+		b.Load(ctx, 1)
+		b.Load(ctx, 1)
+		if r.count() != 1 {
+			t.Errorf("batch function called %d times with cache, want 1", r.count())
+		}
+
+		b.Load(ctx, -1)
+		b.Load(ctx, -1)
+		if r.count() != 3 {
+			t.Errorf("batch function called %d times, want failed keys to be reloaded", r.count())
+		}
+
+		b.Clear(1)
+		b.Load(ctx, 1)
+		b.ClearAll()
+		b.Load(ctx, 1)
+		if r.count() != 5 {
+			t.Errorf("batch function called %d times after clearing, want 5", r.count())
+		}
+	})
+
+	t.Run("caller gives up without failing the batch", func(t *testing.T) {
+		release := make(chan struct{})
+		b := NewBatcher(func(ctx context.Context, keys []int) ([]Result[int], error) {
+			<-release
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return make([]Result[int], len(keys)), nil
+		}, BatcherConfig{Wait: 10 * time.Millisecond})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, err := b.Load(context.Background(), 2)
+			done <- err
+		}()
+		time.AfterFunc(5*time.Millisecond, cancel)
+		if _, err := b.Load(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("Load() = %v, want context.Canceled", err)
+		}
+
+		close(release)
+		if err := <-done; err != nil {
+			t.Errorf("other Load() = %v, want nil", err)
+		}
+	})
+
+	t.Run("panics become errors", func(t *testing.T) {
+		b := NewBatcher(func(context.Context, []int) ([]Result[int], error) {
+			panic("boom")
+		}, BatcherConfig{})
+		var pe *PanicError
+		if _, err := b.Load(context.Background(), 1); !errors.As(err, &pe) {
+			t.Errorf("Load() = %v, want *PanicError", err)
+		}
+	})
+}
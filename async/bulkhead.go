@@ -0,0 +1,204 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBulkheadFull is returned by a Bulkhead when a compartment has no room left
+// for a call, neither running nor queued, or when a queued call has waited for
+// the compartment's MaxWait.
+var ErrBulkheadFull = errors.New("async: bulkhead compartment is full")
+
+// defaultBulkheadConcurrency bounds a compartment when no MaxConcurrent is configured.
+const defaultBulkheadConcurrency = 10
+
+// errBulkheadWait is the cause of a queued call's context once MaxWait has passed.
+var errBulkheadWait = errors.New("async: bulkhead queue wait expired")
+
+// CompartmentConfig bounds one compartment of a Bulkhead.
+type CompartmentConfig struct {
+	// MaxConcurrent is the number of calls that may run at once. Defaults to 10.
+	MaxConcurrent int
+	// MaxQueue is the number of calls that may wait for a running call to
+	// finish; calls beyond it are rejected right away. Zero means no queue.
+	MaxQueue int
+	// MaxWait bounds how long a call may wait in the queue before it is
+	// rejected. Zero means it waits until its context is done.
+	MaxWait time.Duration
+}
+
+// BulkheadConfig configures a Bulkhead.
+type BulkheadConfig struct {
+	// Default bounds the compartments that are not listed in Compartments.
+	Default CompartmentConfig
+	// Compartments bounds the named compartments, overriding Default.
+	Compartments map[string]CompartmentConfig
+	// Clock is the clock MaxWait is measured on. Defaults to the system clock.
+	Clock Clock
+}
+
+// BulkheadStats is a snapshot of a compartment of a Bulkhead.
+type BulkheadStats struct {
+	// Running is the number of calls currently running.
+	Running int
+	// Queued is the number of calls waiting to run.
+	Queued int
+	// Rejected is the number of calls rejected with ErrBulkheadFull so far.
+	Rejected uint64
+}
+
+// Bulkhead isolates the calls to several dependencies into compartments, each
+// with its own bound on running and queued calls, so that a slow dependency
+// can only tie up the goroutines of its own compartment: once it is full,
+// further calls to it fail fast with ErrBulkheadFull while the other
+// compartments keep working. Calls run on the caller's goroutine. Compartments
+// are created on first use. It is safe for concurrent use.
+//
+// Example:
+//
+//	bulkhead := async.NewBulkhead(async.BulkheadConfig{
+//		Default: async.CompartmentConfig{MaxConcurrent: 20, MaxQueue: 50},
+//		Compartments: map[string]async.CompartmentConfig{
+//			"recommendations": {MaxConcurrent: 5, MaxWait: 100 * time.Millisecond},
+//		},
+//	})
+//
+//	recs, err := async.Isolate(ctx, bulkhead, "recommendations", func(ctx context.Context) ([]Item, error) {
+//		return recommender.For(ctx, userID)
+//	})
+//	if errors.Is(err, async.ErrBulkheadFull) {
+//		recs = nil // degrade instead of piling up behind a slow dependency
+//	}
+type Bulkhead struct {
+	cfg BulkheadConfig
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+// compartment is one bounded partition of a Bulkhead.
+type compartment struct {
+	cfg CompartmentConfig
+	sem *Semaphore
+
+	mu       sync.Mutex
+	running  int
+	queued   int
+	rejected uint64
+}
+
+// NewBulkhead returns a Bulkhead with the given configuration.
+func NewBulkhead(cfg BulkheadConfig) *Bulkhead {
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	return &Bulkhead{cfg: cfg, compartments: make(map[string]*compartment)}
+}
+
+// Isolate runs fn in the named compartment of b, on the calling goroutine. If
+// the compartment is busy, the call waits in its queue; if the queue is full
+// too, or MaxWait passes, Isolate returns an error wrapping ErrBulkheadFull
+// without calling fn. If ctx is done while waiting, it returns ctx.Err().
+// Otherwise it returns fn's result.
+func Isolate[T any](ctx context.Context, b *Bulkhead, name string, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	c := b.compartment(name)
+	if err := c.acquire(ctx, b.cfg.Clock); err != nil {
+		if errors.Is(err, ErrBulkheadFull) {
+			err = fmt.Errorf("%w: %q", err, name)
+		}
+		return zero, err
+	}
+	defer c.release()
+
+	return fn(ctx)
+}
+
+// Do is like Isolate for functions that only return an error.
+func (b *Bulkhead) Do(ctx context.Context, name string, fn func(context.Context) error) error {
+	_, err := Isolate(ctx, b, name, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Stats returns a snapshot of the named compartment.
+func (b *Bulkhead) Stats(name string) BulkheadStats {
+	c := b.compartment(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return BulkheadStats{Running: c.running, Queued: c.queued, Rejected: c.rejected}
+}
+
+// compartment returns the named compartment, creating it on first use.
+func (b *Bulkhead) compartment(name string) *compartment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.compartments[name]; ok {
+		return c
+	}
+	cfg, ok := b.cfg.Compartments[name]
+	if !ok {
+		cfg = b.cfg.Default
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultBulkheadConcurrency
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	c := &compartment{cfg: cfg, sem: NewSemaphore(int64(cfg.MaxConcurrent))}
+	b.compartments[name] = c
+	return c
+}
+
+// acquire takes a running slot, waiting in the queue if there is room in it.
+func (c *compartment) acquire(ctx context.Context, clock Clock) error {
+	c.mu.Lock()
+	if c.sem.TryAcquire(1) {
+		c.running++
+		c.mu.Unlock()
+		return nil
+	}
+	if c.queued >= c.cfg.MaxQueue {
+		c.rejected++
+		c.mu.Unlock()
+		return ErrBulkheadFull
+	}
+	c.queued++
+	c.mu.Unlock()
+
+	queueCtx := ctx
+	if c.cfg.MaxWait > 0 {
+		var cancel context.CancelFunc
+		queueCtx, cancel = contextWithTimeout(ctx, clock, c.cfg.MaxWait, errBulkheadWait)
+		defer cancel()
+	}
+	err := c.sem.Acquire(queueCtx, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queued--
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.rejected++
+		return ErrBulkheadFull
+	}
+	c.running++
+	return nil
+}
+
+// release frees a running slot.
+func (c *compartment) release() {
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	c.sem.Release(1)
+}
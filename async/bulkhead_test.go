@@ -0,0 +1,121 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitStats polls the stats of a compartment until cond holds.
+func waitStats(b *Bulkhead, name string, cond func(BulkheadStats) bool) {
+	for !cond(b.Stats(name)) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	t.Run("bounds running and queued calls", func(t *testing.T) {
+		b := NewBulkhead(BulkheadConfig{Default: CompartmentConfig{MaxConcurrent: 2, MaxQueue: 1}})
+		release := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := b.Do(context.Background(), "db", func(context.Context) error {
+					<-release
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		waitStats(b, "db", func(s BulkheadStats) bool { return s.Running == 2 && s.Queued == 1 })
+
+		err := b.Do(context.Background(), "db", func(context.Context) error { return nil })
+		if !errors.Is(err, ErrBulkheadFull) {
+			t.Errorf("Do() error = %v, want ErrBulkheadFull", err)
+		}
+
+		close(release)
+		wg.Wait()
+		if s := b.Stats("db"); s != (BulkheadStats{Rejected: 1}) {
+			t.Errorf("Stats() = %+v, want one rejection and nothing running", s)
+		}
+	})
+
+	t.Run("compartments are isolated", func(t *testing.T) {
+		b := NewBulkhead(BulkheadConfig{
+			Compartments: map[string]CompartmentConfig{"slow": {MaxConcurrent: 1}},
+		})
+		release := make(chan struct{})
+		defer close(release)
+		go func() {
+			_ = b.Do(context.Background(), "slow", func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		waitStats(b, "slow", func(s BulkheadStats) bool { return s.Running == 1 })
+
+		if err := b.Do(context.Background(), "slow", func(context.Context) error { return nil }); !errors.Is(err, ErrBulkheadFull) {
+			t.Errorf("Do(slow) error = %v, want ErrBulkheadFull", err)
+		}
+		v, err := Isolate(context.Background(), b, "fast", func(context.Context) (int, error) { return 1, nil })
+		if v != 1 || err != nil {
+			t.Errorf("Isolate(fast) = %v, %v, want 1, nil", v, err)
+		}
+	})
+
+	t.Run("queued calls give up after MaxWait", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		b := NewBulkhead(BulkheadConfig{
+			Default: CompartmentConfig{MaxConcurrent: 1, MaxQueue: 1, MaxWait: time.Second},
+			Clock:   clock,
+		})
+		release := make(chan struct{})
+		defer close(release)
+		go func() {
+			_ = b.Do(context.Background(), "api", func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		waitStats(b, "api", func(s BulkheadStats) bool { return s.Running == 1 })
+
+		errc := make(chan error, 1)
+		go func() {
+			errc <- b.Do(context.Background(), "api", func(context.Context) error { return nil })
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if err := <-errc; !errors.Is(err, ErrBulkheadFull) {
+			t.Errorf("Do() error = %v, want ErrBulkheadFull", err)
+		}
+	})
+
+	t.Run("queued calls stop when the context is done", func(t *testing.T) {
+		b := NewBulkhead(BulkheadConfig{Default: CompartmentConfig{MaxConcurrent: 1, MaxQueue: 1}})
+		release := make(chan struct{})
+		defer close(release)
+		go func() {
+			_ = b.Do(context.Background(), "api", func(context.Context) error {
+				<-release
+				return nil
+			})
+		}()
+		waitStats(b, "api", func(s BulkheadStats) bool { return s.Running == 1 })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := b.Do(ctx, "api", func(context.Context) error { return nil })
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Do() error = %v, want DeadlineExceeded", err)
+		}
+		if s := b.Stats("api"); s.Queued != 0 || s.Rejected != 0 {
+			t.Errorf("Stats() = %+v, want the cancelled call neither queued nor rejected", s)
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package async
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a CircuitBreaker that is rejecting calls, either
+// because it is open or because its half-open trial calls are all in flight.
+var ErrCircuitOpen = errors.New("async: circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// StateClosed lets every call through and records its outcome.
+	StateClosed CircuitState = iota
+	// StateOpen rejects every call with ErrCircuitOpen until the cool-down has passed.
+	StateOpen
+	// StateHalfOpen lets a limited number of trial calls through to probe the dependency.
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	defaultBreakerFailures  = 5
+	defaultBreakerWindow    = 10 * time.Second
+	defaultBreakerBuckets   = 10
+	defaultBreakerCoolDown  = 10 * time.Second
+	defaultBreakerMinCalls  = 10
+	defaultBreakerTrialRuns = 1
+)
+
+// CircuitBreakerConfig configures a CircuitBreaker. Zero fields select defaults.
+// If neither ConsecutiveFailures nor FailureRate is set, the breaker trips after
+// 5 consecutive failures.
+type CircuitBreakerConfig struct {
+	// ConsecutiveFailures trips the breaker after that many failures in a row.
+	ConsecutiveFailures int
+	// FailureRate trips the breaker when the fraction of failed calls within
+	// the rolling window reaches it (0 < FailureRate <= 1).
+	FailureRate float64
+	// MinRequests is the number of calls the rolling window must hold before
+	// FailureRate is considered. Defaults to 10.
+	MinRequests int
+	// Window is the length of the rolling window used by FailureRate. Defaults to 10s.
+	Window time.Duration
+	// WindowBuckets is the number of buckets the window is divided into; older
+	// buckets expire one at a time as the window rolls. Defaults to 10.
+	WindowBuckets int
+	// CoolDown is how long the breaker stays open before allowing trial calls. Defaults to 10s.
+	CoolDown time.Duration
+	// HalfOpenMaxCalls is the number of trial calls allowed while half-open.
+	// That many successful trials close the breaker; a failed one re-opens it. Defaults to 1.
+	HalfOpenMaxCalls int
+	// IsFailure reports whether an error counts as a failure. If nil, every
+	// non-nil error does. Errors it rejects are returned but count as successes.
+	IsFailure func(error) bool
+	// OnStateChange, if set, is called after every state transition.
+	OnStateChange func(from, to CircuitState)
+	// Clock is the clock the window and the cool-down are measured on.
+	// Defaults to the system clock.
+	Clock Clock
+}
+
+// CircuitBreaker stops calling a failing dependency for a while so that it can
+// recover and callers fail fast instead of piling up retries. It starts closed,
+// opens when failures reach the configured threshold, rejects calls with
+// ErrCircuitOpen during the cool-down, then half-opens to let a few trial calls
+// decide whether to close again or re-open.
+//
+// Example:
+//
+//	cb := async.NewCircuitBreaker(async.CircuitBreakerConfig{
+//		FailureRate: 0.5,
+//		MinRequests: 20,
+//		CoolDown:    30 * time.Second,
+//	})
+//
+//	user, err := async.Execute(cb, func() (User, error) {
+//		return client.GetUser(ctx, id)
+//	})
+//	if errors.Is(err, async.ErrCircuitOpen) {
+//		return cachedUser(id)
+//	}
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64 // incremented on every transition to ignore stale results
+	openedAt    time.Time
+	consecutive int
+	window      rollingWindow
+	trials      int // half-open calls in flight
+	successes   int // successful half-open calls
+	transitions []func()
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker with the given configuration.
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.ConsecutiveFailures <= 0 && cfg.FailureRate <= 0 {
+		cfg.ConsecutiveFailures = defaultBreakerFailures
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultBreakerMinCalls
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultBreakerWindow
+	}
+	if cfg.WindowBuckets <= 0 {
+		cfg.WindowBuckets = defaultBreakerBuckets
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = defaultBreakerCoolDown
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = defaultBreakerTrialRuns
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+
+	return &CircuitBreaker{
+		cfg:    cfg,
+		window: newRollingWindow(cfg.Window, cfg.WindowBuckets, cfg.Clock.Now()),
+	}
+}
+
+// Execute runs fn through cb. It returns ErrCircuitOpen without calling fn if
+// the breaker is rejecting calls; otherwise it returns fn's result and records
+// its outcome. A panic in fn is recorded as a failure and then re-raised.
+func Execute[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error) {
+	generation, err := cb.before()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	failed := true
+	defer func() {
+		cb.after(generation, failed)
+	}()
+
+	value, err := fn()
+	failed = err != nil && (cb.cfg.IsFailure == nil || cb.cfg.IsFailure(err))
+	return value, err
+}
+
+// Do is like Execute for functions that only return an error.
+func (cb *CircuitBreaker) Do(fn func() error) error {
+	_, err := Execute(cb, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// State returns the breaker's current state.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.refresh(cb.cfg.Clock.Now())
+	return cb.state
+}
+
+// before checks whether a call may proceed and returns the generation it belongs to.
+func (cb *CircuitBreaker) before() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	cb.refresh(cb.cfg.Clock.Now())
+	switch cb.state {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.trials >= cb.cfg.HalfOpenMaxCalls {
+			return 0, ErrCircuitOpen
+		}
+		cb.trials++
+	}
+	return cb.generation, nil
+}
+
+// after records the outcome of a call started in the given generation.
+func (cb *CircuitBreaker) after(generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	now := cb.cfg.Clock.Now()
+	cb.refresh(now)
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case StateClosed:
+		cb.window.record(now, failed)
+		if failed {
+			cb.consecutive++
+		} else {
+			cb.consecutive = 0
+		}
+		if cb.shouldTrip(now) {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		cb.trials--
+		if failed {
+			cb.setState(StateOpen, now)
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.cfg.HalfOpenMaxCalls {
+			cb.setState(StateClosed, now)
+		}
+	}
+}
+
+// shouldTrip reports whether the recorded failures reach a threshold. cb.mu must be held.
+func (cb *CircuitBreaker) shouldTrip(now time.Time) bool {
+	if cb.cfg.ConsecutiveFailures > 0 && cb.consecutive >= cb.cfg.ConsecutiveFailures {
+		return true
+	}
+	if cb.cfg.FailureRate > 0 {
+		total, failures := cb.window.totals(now)
+		if total >= cb.cfg.MinRequests && float64(failures)/float64(total) >= cb.cfg.FailureRate {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh moves an open breaker to half-open once its cool-down has passed. cb.mu must be held.
+func (cb *CircuitBreaker) refresh(now time.Time) {
+	if cb.state == StateOpen && now.Sub(cb.openedAt) >= cb.cfg.CoolDown {
+		cb.setState(StateHalfOpen, now)
+	}
+}
+
+// setState switches to state to, resetting the counters of the new state and
+// scheduling the OnStateChange callback. cb.mu must be held.
+func (cb *CircuitBreaker) setState(to CircuitState, now time.Time) {
+	from := cb.state
+	if from == to {
+		return
+	}
+
+	cb.state = to
+	cb.generation++
+	cb.consecutive = 0
+	cb.trials = 0
+	cb.successes = 0
+	switch to {
+	case StateOpen:
+		cb.openedAt = now
+	case StateClosed:
+		cb.window.reset(now)
+	}
+
+	if cb.cfg.OnStateChange != nil {
+		cb.transitions = append(cb.transitions, func() { cb.cfg.OnStateChange(from, to) })
+	}
+}
+
+// unlock releases cb.mu and then runs the state-change callbacks scheduled while
+// it was held, so that callbacks may call back into the breaker.
+func (cb *CircuitBreaker) unlock() {
+	transitions := cb.transitions
+	cb.transitions = nil
+	cb.mu.Unlock()
+
+	for _, notify := range transitions {
+		notify()
+	}
+}
+
+// rollingWindow counts calls and failures over a sliding time window made of
+// fixed-width buckets.
+type rollingWindow struct {
+	epoch   time.Time
+	width   time.Duration
+	buckets []windowBucket
+}
+
+// windowBucket holds the counts of one slot of a rollingWindow.
+type windowBucket struct {
+	slot     int64
+	total    int
+	failures int
+}
+
+// newRollingWindow returns a window of the given length split into n buckets.
+func newRollingWindow(length time.Duration, n int, now time.Time) rollingWindow {
+	width := length / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	w := rollingWindow{width: width, buckets: make([]windowBucket, n)}
+	w.reset(now)
+	return w
+}
+
+// slot returns the index of the time slot containing now.
+func (w *rollingWindow) slot(now time.Time) int64 {
+	return int64(now.Sub(w.epoch) / w.width)
+}
+
+// record counts one call in the bucket for now.
+func (w *rollingWindow) record(now time.Time, failed bool) {
+	slot := w.slot(now)
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.slot != slot {
+		*b = windowBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+// totals returns the number of calls and failures within the window ending at now.
+func (w *rollingWindow) totals(now time.Time) (total, failures int) {
+	slot := w.slot(now)
+	oldest := slot - int64(len(w.buckets)) + 1
+	for _, b := range w.buckets {
+		if b.slot >= oldest && b.slot <= slot {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+// reset forgets every recorded call.
+func (w *rollingWindow) reset(now time.Time) {
+	w.epoch = now
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{slot: -1}
+	}
+}
@@ -0,0 +1,195 @@
+package async
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Run("cools down on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Minute, Clock: clock})
+		_ = cb.Do(func() error { return errors.New("boom") })
+		if cb.State() != StateOpen {
+			t.Fatalf("State() = %v, want open", cb.State())
+		}
+
+		clock.Advance(59 * time.Second)
+		if cb.State() != StateOpen {
+			t.Errorf("State() = %v before the cool-down ended, want open", cb.State())
+		}
+		clock.Advance(time.Second)
+		if cb.State() != StateHalfOpen {
+			t.Errorf("State() = %v after the cool-down, want half-open", cb.State())
+		}
+	})
+
+	errBoom := errors.New("boom")
+	fail := func() error { return errBoom }
+	succeed := func() error { return nil }
+
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 3, CoolDown: time.Hour})
+
+		for i := 0; i < 3; i++ {
+			if err := cb.Do(fail); err != errBoom {
+				t.Fatalf("Do() = %v, want %v", err, errBoom)
+			}
+		}
+		if cb.State() != StateOpen {
+			t.Fatalf("State() = %v, want open", cb.State())
+		}
+
+		called := false
+		err := cb.Do(func() error { called = true; return nil })
+		if err != ErrCircuitOpen || called {
+			t.Errorf("Do() on open breaker = %v (called=%v), want ErrCircuitOpen without calling", err, called)
+		}
+	})
+
+	t.Run("successes reset the consecutive count", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 2})
+
+		cb.Do(fail)
+		cb.Do(succeed)
+		cb.Do(fail)
+		if cb.State() != StateClosed {
+			t.Errorf("State() = %v, want closed", cb.State())
+		}
+	})
+
+	t.Run("opens on failure rate", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{FailureRate: 0.5, MinRequests: 4, CoolDown: time.Hour})
+
+		cb.Do(succeed)
+		cb.Do(fail)
+		cb.Do(succeed)
+		if cb.State() != StateClosed {
+			t.Fatalf("State() below MinRequests = %v, want closed", cb.State())
+		}
+		cb.Do(fail)
+		if cb.State() != StateOpen {
+			t.Errorf("State() = %v, want open", cb.State())
+		}
+	})
+
+	t.Run("half-open trial closes the breaker", func(t *testing.T) {
+		var mu sync.Mutex
+		var transitions []string
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			ConsecutiveFailures: 1,
+			CoolDown:            10 * time.Millisecond,
+			OnStateChange: func(from, to CircuitState) {
+				mu.Lock()
+				transitions = append(transitions, from.String()+"->"+to.String())
+				mu.Unlock()
+			},
+		})
+
+		cb.Do(fail)
+		time.Sleep(20 * time.Millisecond)
+		if cb.State() != StateHalfOpen {
+			t.Fatalf("State() after cool-down = %v, want half-open", cb.State())
+		}
+		if err := cb.Do(succeed); err != nil {
+			t.Fatalf("trial Do() = %v, want nil", err)
+		}
+		if cb.State() != StateClosed {
+			t.Errorf("State() after successful trial = %v, want closed", cb.State())
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		want := []string{"closed->open", "open->half-open", "half-open->closed"}
+		if len(transitions) != len(want) {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+		for i := range want {
+			if transitions[i] != want[i] {
+				t.Errorf("transitions = %v, want %v", transitions, want)
+			}
+		}
+	})
+
+	t.Run("failed trial re-opens the breaker", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: 10 * time.Millisecond})
+
+		cb.Do(fail)
+		time.Sleep(20 * time.Millisecond)
+		cb.Do(fail)
+		if cb.State() != StateOpen {
+			t.Errorf("State() after failed trial = %v, want open", cb.State())
+		}
+	})
+
+	t.Run("limits half-open trial calls", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: 10 * time.Millisecond, HalfOpenMaxCalls: 1})
+
+		cb.Do(fail)
+		time.Sleep(20 * time.Millisecond)
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go cb.Do(func() error { close(started); <-release; return nil })
+		<-started
+
+		if err := cb.Do(succeed); err != ErrCircuitOpen {
+			t.Errorf("second trial Do() = %v, want ErrCircuitOpen", err)
+		}
+		close(release)
+	})
+
+	t.Run("IsFailure filters errors", func(t *testing.T) {
+		errIgnored := errors.New("ignored")
+		cb := NewCircuitBreaker(CircuitBreakerConfig{
+			ConsecutiveFailures: 1,
+			IsFailure:           func(err error) bool { return err != errIgnored },
+		})
+
+		if err := cb.Do(func() error { return errIgnored }); err != errIgnored {
+			t.Errorf("Do() = %v, want %v", err, errIgnored)
+		}
+		if cb.State() != StateClosed {
+			t.Errorf("State() = %v, want closed", cb.State())
+		}
+	})
+
+	t.Run("Execute returns values", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{})
+		got, err := Execute(cb, func() (string, error) { return "ok", nil })
+		if err != nil || got != "ok" {
+			t.Errorf("Execute() = %q, %v, want \"ok\", nil", got, err)
+		}
+	})
+
+	t.Run("panics count as failures", func(t *testing.T) {
+		cb := NewCircuitBreaker(CircuitBreakerConfig{ConsecutiveFailures: 1})
+
+		func() {
+			defer func() { recover() }()
+			cb.Do(func() error { panic("boom") })
+		}()
+		if cb.State() != StateOpen {
+			t.Errorf("State() after panic = %v, want open", cb.State())
+		}
+	})
+}
+
+func TestRollingWindow(t *testing.T) {
+	start := time.Now()
+	w := newRollingWindow(time.Second, 10, start)
+
+	w.record(start, true)
+	w.record(start.Add(500*time.Millisecond), false)
+	if total, failures := w.totals(start.Add(900 * time.Millisecond)); total != 2 || failures != 1 {
+		t.Errorf("totals() = %d, %d, want 2, 1", total, failures)
+	}
+	if total, failures := w.totals(start.Add(1200 * time.Millisecond)); total != 1 || failures != 0 {
+		t.Errorf("totals() after the first bucket expired = %d, %d, want 1, 0", total, failures)
+	}
+	if total, _ := w.totals(start.Add(time.Minute)); total != 0 {
+		t.Errorf("totals() long after = %d, want 0", total)
+	}
+}
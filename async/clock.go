@@ -0,0 +1,341 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by the time-based helpers of this package.
+// It defaults to the system clock; tests pass a FakeClock with WithClock, or in
+// the Clock field of a configuration, to control time instead of sleeping.
+//
+// Example:
+//
+//	clock := async.NewFakeClock(time.Now())
+//	save := async.NewDebouncer(store, time.Second, async.WithClock(clock))
+//	save.Call(doc)
+//	clock.Advance(time.Second) // store(doc) has run when Advance returns
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time once d has passed.
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f on its own goroutine once d has passed. The returned
+	// Timer can stop the call; its channel is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTimer returns a Timer that sends the current time on its channel once d has passed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker returns a Ticker that sends the current time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+	// Sleep pauses the calling goroutine for at least d.
+	Sleep(d time.Duration)
+}
+
+// Timer is a single event created by a Clock, like a *time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent, or nil for AfterFunc timers.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing and reports whether it was active.
+	Stop() bool
+	// Reset makes the timer fire after d and reports whether it was active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker sends the time at regular intervals, like a *time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are sent.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset changes the period of the ticker to d and restarts it.
+	Reset(d time.Duration)
+}
+
+// RealClock returns the Clock backed by the time package, which the helpers use
+// when no other clock is configured.
+func RealClock() Clock {
+	return realClock{}
+}
+
+// realClock implements Clock with the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTimer adapts a *time.Timer to Timer.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// realTicker adapts a *time.Ticker to Ticker.
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Stop()                 { t.t.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
+
+// FakeClock is a Clock whose time only moves when Advance is called, which
+// makes code built on the time-based helpers testable without sleeping.
+// It is safe for concurrent use.
+//
+// Advance fires the timers that fall due in order, setting the time to each
+// deadline in turn. Timer and ticker channels are sent to without blocking, and
+// AfterFunc functions run on the goroutine calling Advance, so that their effects
+// are visible once it returns. Since the code under test usually creates its
+// timers on other goroutines, tests call BlockUntil before advancing the time.
+//
+// Example:
+//
+//	clock := async.NewFakeClock(time.Now())
+//	done := make(chan error, 1)
+//	go func() {
+//		_, err := async.RetryCtx(ctx, policy, fetch, async.WithClock(clock))
+//		done <- err
+//	}()
+//
+//	clock.BlockUntil(1) // the first retry is waiting
+//	clock.Advance(policy.InitialDelay)
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // signalled when timers are added
+	now     time.Time
+	timers  []*fakeTimer // active timers, in creation order
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has advanced by d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// AfterFunc calls f once the clock has advanced by d, on the goroutine calling
+// Advance. If d is not positive, f is called right away on its own goroutine.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	t.Reset(d)
+	return t
+}
+
+// NewTimer returns a Timer that fires once the clock has advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a Ticker that fires every time the clock advances by d.
+// It panics if d is not positive, like time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("async: non-positive interval for FakeClock.NewTicker")
+	}
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return &fakeTicker{t}
+}
+
+// Sleep blocks until the clock has advanced by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-c.After(d)
+}
+
+// Advance moves the clock forward by d, firing the timers that fall due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		t := c.next(end)
+		if t == nil {
+			if end.After(c.now) {
+				c.now = end
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		now := c.now
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			c.remove(t)
+		}
+		c.mu.Unlock()
+
+		t.fire(now)
+	}
+}
+
+// BlockUntil blocks until at least n timers, tickers and sleeps are waiting on
+// the clock, so that a test can advance the time once the code under test has
+// started waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// Len returns the number of timers, tickers and sleeps waiting on the clock.
+func (c *FakeClock) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// next returns the earliest timer due by end, preferring the oldest of timers
+// due at the same time, or nil. c.mu must be held.
+func (c *FakeClock) next(end time.Time) *fakeTimer {
+	var first *fakeTimer
+	for _, t := range c.timers {
+		if !t.when.After(end) && (first == nil || t.when.Before(first.when)) {
+			first = t
+		}
+	}
+	return first
+}
+
+// remove drops t from the active timers. c.mu must be held.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a timer, ticker or AfterFunc call of a FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	c      chan time.Time
+	fn     func()
+	period time.Duration // non-zero for tickers
+	when   time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	active := c.remove(t)
+	if d <= 0 && t.period == 0 {
+		now := c.now
+		c.mu.Unlock()
+		if t.fn != nil {
+			go t.fn()
+		} else {
+			t.fire(now)
+		}
+		return active
+	}
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+	c.mu.Unlock()
+	return active
+}
+
+// fire sends now on the channel of the timer, dropping it if the previous
+// value was not received, or calls its function.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+// fakeTicker adapts a periodic fakeTimer to Ticker.
+type fakeTicker struct {
+	t *fakeTimer
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.t.Stop()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("async: non-positive interval for FakeClock ticker Reset")
+	}
+	t.t.clock.mu.Lock()
+	t.t.period = d
+	t.t.clock.mu.Unlock()
+	t.t.Reset(d)
+}
+
+// contextWithTimeout is context.WithTimeoutCause measured on clock. With the
+// real clock the context carries its deadline; with other clocks it is only
+// cancelled, with cause, once the clock has advanced by d.
+func contextWithTimeout(ctx context.Context, clock Clock, d time.Duration, cause error) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(realClock); ok {
+		return context.WithTimeoutCause(ctx, d, cause)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := clock.AfterFunc(d, func() { cancel(cause) })
+	return ctx, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
@@ -0,0 +1,206 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Advance fires timers in order", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var fired []time.Duration
+		for _, d := range []time.Duration{30, 10, 20} {
+			d := d * time.Millisecond
+			clock.AfterFunc(d, func() {
+				if now := clock.Now(); !now.Equal(start.Add(d)) {
+					t.Errorf("timer of %v fired at %v", d, now.Sub(start))
+				}
+				fired = append(fired, d)
+			})
+		}
+
+		clock.Advance(25 * time.Millisecond)
+		if len(fired) != 2 || fired[0] != 10*time.Millisecond || fired[1] != 20*time.Millisecond {
+			t.Fatalf("fired = %v, want [10ms 20ms]", fired)
+		}
+		if got := clock.Now().Sub(start); got != 25*time.Millisecond {
+			t.Errorf("Now() = start+%v, want start+25ms", got)
+		}
+		if clock.Len() != 1 {
+			t.Errorf("Len() = %d, want 1", clock.Len())
+		}
+
+		clock.Advance(10 * time.Millisecond)
+		if len(fired) != 3 {
+			t.Errorf("fired = %v, want the third timer too", fired)
+		}
+	})
+
+	t.Run("Stop and Reset", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+		if !timer.Stop() {
+			t.Error("Stop() = false for an active timer")
+		}
+		clock.Advance(2 * time.Second)
+		select {
+		case <-timer.C():
+			t.Fatal("stopped timer fired")
+		default:
+		}
+
+		if timer.Reset(time.Second) {
+			t.Error("Reset() = true for a stopped timer")
+		}
+		clock.Advance(time.Second)
+		select {
+		case now := <-timer.C():
+			if !now.Equal(start.Add(3 * time.Second)) {
+				t.Errorf("timer sent start+%v, want start+3s", now.Sub(start))
+			}
+		default:
+			t.Fatal("reset timer did not fire")
+		}
+	})
+
+	t.Run("tickers drop missed ticks", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+
+		clock.Advance(time.Second)
+		if now := <-ticker.C(); !now.Equal(start.Add(time.Second)) {
+			t.Errorf("first tick at start+%v, want start+1s", now.Sub(start))
+		}
+
+		clock.Advance(3 * time.Second)
+		if now := <-ticker.C(); !now.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("buffered tick at start+%v, want start+2s", now.Sub(start))
+		}
+		select {
+		case <-ticker.C():
+			t.Error("ticker buffered more than one tick")
+		default:
+		}
+
+		ticker.Reset(10 * time.Second)
+		clock.Advance(9 * time.Second)
+		select {
+		case <-ticker.C():
+			t.Error("ticker fired before its new period")
+		default:
+		}
+
+		ticker.Stop()
+		clock.Advance(time.Minute)
+		select {
+		case <-ticker.C():
+			t.Error("stopped ticker fired")
+		default:
+		}
+	})
+
+	t.Run("Sleep and BlockUntil", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				clock.Sleep(time.Minute)
+			}()
+		}
+
+		clock.BlockUntil(3)
+		clock.Advance(time.Minute)
+		wg.Wait()
+		if clock.Len() != 0 {
+			t.Errorf("Len() = %d after the sleeps returned, want 0", clock.Len())
+		}
+	})
+
+	t.Run("non-positive durations fire right away", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		select {
+		case <-clock.After(0):
+		default:
+			t.Error("After(0) did not fire")
+		}
+
+		done := make(chan struct{})
+		clock.AfterFunc(-time.Second, func() { close(done) })
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("AfterFunc with a negative duration did not run")
+		}
+		clock.Sleep(0)
+	})
+}
+
+func TestRealClock(t *testing.T) {
+	clock := RealClock()
+	before := time.Now()
+	if now := clock.Now(); now.Before(before) {
+		t.Errorf("Now() = %v, before %v", now, before)
+	}
+
+	<-clock.After(time.Millisecond)
+	clock.Sleep(time.Millisecond)
+
+	done := make(chan struct{})
+	clock.AfterFunc(time.Millisecond, func() { close(done) })
+	<-done
+
+	timer := clock.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Error("Stop() = false for an active timer")
+	}
+
+	ticker := clock.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+}
+
+func TestContextWithTimeout(t *testing.T) {
+	errExpired := errors.New("expired")
+
+	t.Run("real clock sets a deadline", func(t *testing.T) {
+		ctx, cancel := contextWithTimeout(context.Background(), RealClock(), time.Hour, errExpired)
+		defer cancel()
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("context has no deadline")
+		}
+	})
+
+	t.Run("fake clock cancels on advance", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		ctx, cancel := contextWithTimeout(context.Background(), clock, time.Hour, errExpired)
+		defer cancel()
+
+		clock.Advance(time.Hour - time.Nanosecond)
+		if ctx.Err() != nil {
+			t.Fatal("context cancelled before the timeout")
+		}
+		clock.Advance(time.Nanosecond)
+		if cause := context.Cause(ctx); cause != errExpired {
+			t.Errorf("Cause() = %v, want %v", cause, errExpired)
+		}
+	})
+
+	t.Run("cancel stops the timer", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		ctx, cancel := contextWithTimeout(context.Background(), clock, time.Hour, errExpired)
+		cancel()
+		if clock.Len() != 0 {
+			t.Errorf("Len() = %d after cancel, want 0", clock.Len())
+		}
+		if cause := context.Cause(ctx); cause != context.Canceled {
+			t.Errorf("Cause() = %v, want %v", cause, context.Canceled)
+		}
+	})
+}
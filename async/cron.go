@@ -0,0 +1,307 @@
+package async
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the times at which a Scheduler runs a job.
+type Schedule interface {
+	// Next returns the first activation time strictly after t, or the zero
+	// time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule that activates every interval, starting one interval
+// after the job is added. Intervals shorter than a millisecond are rounded up.
+//
+// Example:
+//
+//	scheduler.Add("flush-metrics", async.Every(10*time.Second), flushMetrics)
+func Every(interval time.Duration) Schedule {
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return everySchedule(interval)
+}
+
+// everySchedule activates at a fixed interval.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bit set of the
+// values it matches.
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a day field written as * or ?, which makes the
+	// other day field decide alone; when both are restricted, either may match.
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+// cronField describes the range and value names of one cron field.
+type cronField struct {
+	name   string
+	lo, hi int
+	names  map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", lo: 0, hi: 59}
+	cronMinute = cronField{name: "minute", lo: 0, hi: 59}
+	cronHour   = cronField{name: "hour", lo: 0, hi: 23}
+	cronDom    = cronField{name: "day of month", lo: 1, hi: 31}
+	cronMonth  = cronField{name: "month", lo: 1, hi: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Day of week accepts 7 as well as 0 for Sunday.
+	cronDow = cronField{name: "day of week", lo: 0, hi: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronDescriptors are the predefined schedules accepted by ParseCron.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron parses a cron expression into a Schedule. It accepts the standard
+// five fields (minute, hour, day of month, month, day of week) or six fields
+// with a leading seconds field. Fields support *, ?, values, ranges (1-5),
+// steps (*/15, 0-30/5), lists (1,15,30) and the names JAN-DEC and SUN-SAT.
+// When both day fields are restricted, a day matching either of them is
+// selected, as in classic cron.
+//
+// The descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight,
+// @hourly and "@every <duration>" are accepted as well. Times are computed in
+// the local time zone unless the expression starts with CRON_TZ=<zone> or TZ=<zone>.
+//
+// Example:
+//
+//	// 02:30 every weekday, Paris time.
+//	schedule, err := async.ParseCron("CRON_TZ=Europe/Paris 30 2 * * MON-FRI")
+func ParseCron(expr string) (Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	loc := time.Local
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("async: cron expression %q: %w", expr, err)
+		}
+		loc = l
+		spec = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("async: cron expression %q: invalid interval", expr)
+		}
+		return Every(d), nil
+	}
+	if d, ok := cronDescriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("async: cron expression %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	s := &cronSchedule{loc: loc}
+	var err error
+	parse := func(dst *uint64, text string, f cronField) {
+		if err == nil {
+			*dst, err = parseCronField(text, f)
+		}
+	}
+	parse(&s.second, fields[0], cronSecond)
+	parse(&s.minute, fields[1], cronMinute)
+	parse(&s.hour, fields[2], cronHour)
+	parse(&s.dom, fields[3], cronDom)
+	parse(&s.month, fields[4], cronMonth)
+	parse(&s.dow, fields[5], cronDow)
+	if err != nil {
+		return nil, fmt.Errorf("async: cron expression %q: %w", expr, err)
+	}
+
+	// Fold Sunday-as-7 onto 0.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domAny = fields[3] == "*" || fields[3] == "?"
+	s.dowAny = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+// parseCronField parses one comma-separated cron field into a bit set.
+func parseCronField(text string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepText, f.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*" || rng == "?":
+			lo, hi = f.lo, f.hi
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseCronValue(a, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(b, f); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseCronValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if hasStep {
+				hi = f.hi
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue parses a single number or name of a cron field.
+func parseCronValue(text string, f cronField) (int, error) {
+	if v, ok := f.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.lo || v > f.hi {
+		return 0, fmt.Errorf("invalid value %q in %s field", text, f.name)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching every field of the schedule.
+// It gives up and returns the zero time if there is no match within five years,
+// which only happens for impossible dates such as February 30th.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	orig := t.Location()
+	t = t.In(s.loc).Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + 5
+
+	// Each loop advances the time to the next value matching its field, resetting
+	// the smaller fields the first time it moves; overflowing into a larger field
+	// restarts the search from the top.
+	reset := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// Daylight saving changes may leave the time off midnight.
+		if h := t.Hour(); h != 0 {
+			if h > 12 {
+				t = t.Add(time.Duration(24-h) * time.Hour)
+			} else {
+				t = t.Add(-time.Duration(h) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !reset {
+			reset = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !reset {
+			reset = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t.In(orig)
+}
+
+// dayMatches reports whether the day of t matches the day-of-month and
+// day-of-week fields.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
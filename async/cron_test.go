@@ -0,0 +1,139 @@
+package async
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, time.January, 15, 10, 20, 30, 0, time.UTC) // a Monday
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		{"* * * * *", []time.Time{
+			time.Date(2024, 1, 15, 10, 21, 0, 0, time.UTC),
+			time.Date(2024, 1, 15, 10, 22, 0, 0, time.UTC),
+		}},
+		{"*/15 * * * * *", []time.Time{
+			time.Date(2024, 1, 15, 10, 20, 45, 0, time.UTC),
+			time.Date(2024, 1, 15, 10, 21, 0, 0, time.UTC),
+		}},
+		{"30 2 * * *", []time.Time{
+			time.Date(2024, 1, 16, 2, 30, 0, 0, time.UTC),
+			time.Date(2024, 1, 17, 2, 30, 0, 0, time.UTC),
+		}},
+		{"0 9 * * MON-FRI", []time.Time{
+			time.Date(2024, 1, 16, 9, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 * * 0", []time.Time{
+			time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 * * 7", []time.Time{
+			time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 1,15 * *", []time.Time{
+			time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+		}},
+		// Both day fields restricted: either may match.
+		{"0 0 20 * FRI", []time.Time{
+			time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 12 29 feb *", []time.Time{
+			time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+			time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		}},
+		{"0-10/5 8 * * *", []time.Time{
+			time.Date(2024, 1, 16, 8, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 16, 8, 5, 0, 0, time.UTC),
+			time.Date(2024, 1, 16, 8, 10, 0, 0, time.UTC),
+			time.Date(2024, 1, 17, 8, 0, 0, 0, time.UTC),
+		}},
+		{"@monthly", []time.Time{
+			time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"@every 90s", []time.Time{
+			base.Add(90 * time.Second),
+			base.Add(180 * time.Second),
+		}},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseCron("TZ=UTC " + tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q) error = %v", tt.expr, err)
+			continue
+		}
+		next := base
+		for _, want := range tt.want {
+			next = s.Next(next)
+			if !next.Equal(want) {
+				t.Errorf("ParseCron(%q): Next() = %v, want %v", tt.expr, next, want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseCronTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	s, err := ParseCron("CRON_TZ=America/New_York 0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC))
+	want := time.Date(2024, time.July, 1, 9, 0, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	if got.Location() != time.UTC {
+		t.Errorf("Next() location = %v, want the location of the argument", got.Location())
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every nope",
+		"CRON_TZ=Nowhere/Nothing * * * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) error = nil, want error", expr)
+		}
+	}
+}
+
+func TestParseCronImpossible(t *testing.T) {
+	s, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next() for February 30th = %v, want zero time", next)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	base := time.Now()
+	if got := Every(time.Minute).Next(base); !got.Equal(base.Add(time.Minute)) {
+		t.Errorf("Next() = %v, want %v", got, base.Add(time.Minute))
+	}
+}
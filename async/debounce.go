@@ -0,0 +1,320 @@
+package async
+
+import (
+	"sync"
+	"time"
+)
+
+// Debounce creates a debounced version of a function that delays execution
+// until after the specified duration has elapsed since the last call.
+//
+// Example:
+//
+//	debouncedFunc := async.Debounce(func() {
+//		fmt.Println("Executed after delay")
+//	}, 100*time.Millisecond)
+//
+//	debouncedFunc() // Will be cancelled
+//	debouncedFunc() // Will be cancelled
+//	debouncedFunc() // Will execute after 100ms
+//
+// Since f runs on its own goroutine, a panic in f is recovered and passed to the
+// handler set with WithPanicHandler; without one, it is re-raised as a
+// *PanicError by the next call of the debounced function.
+// Use NewDebouncer to pass arguments or to cancel and flush pending calls.
+func Debounce(f func(), delay time.Duration, opts ...Option) func() {
+	d := NewDebouncer(func(struct{}) { f() }, delay, opts...)
+	return func() {
+		d.Call(struct{}{})
+	}
+}
+
+// Throttle creates a throttled version of a function that limits execution
+// to at most once per specified duration.
+//
+// Example:
+//
+//	throttledFunc := async.Throttle(func() {
+//		fmt.Println("Executed at most once per interval")
+//	}, 100*time.Millisecond)
+//
+//	throttledFunc() // Executes immediately
+//	throttledFunc() // Ignored (too soon)
+//	time.Sleep(150 * time.Millisecond)
+//	throttledFunc() // Executes (enough time has passed)
+//
+// Calls made too soon are dropped. Use NewThrottler to run the latest of them
+// once the interval has passed. The interval is measured on the clock set with WithClock.
+func Throttle(f func(), interval time.Duration, opts ...Option) func() {
+	clock := applyOptions(opts).clock
+	var lastExecution time.Time
+	var mu sync.Mutex
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := clock.Now()
+		if now.Sub(lastExecution) >= interval {
+			lastExecution = now
+			f()
+		}
+	}
+}
+
+// Debouncer delays calls to a function until they stop arriving, passing it the
+// argument of the latest call. It is created by NewDebouncer and NewThrottler,
+// and is safe for concurrent use.
+//
+// A burst of calls starts with the first call made while the Debouncer is idle.
+// With WithLeading(true) the function runs right away on that call; with
+// WithTrailing(true), the default, it runs once no call has arrived for the
+// delay, with the latest argument, if any call is still pending. WithMaxWait
+// bounds how long a burst may postpone the function, and WithClock sets the
+// clock the delays are measured on.
+//
+// The function runs on the goroutine calling Call or Flush for the leading edge
+// and flushes, and on its own goroutine otherwise. A panic in it is recovered
+// and passed to the handler set with WithPanicHandler. Without a handler, it is
+// re-raised as a *PanicError: right away on the calling goroutine, or by the
+// next call to Call, Cancel or Flush if the function ran on its own goroutine.
+//
+// Example:
+//
+//	search := async.NewDebouncer(func(query string) {
+//		results <- index.Search(query)
+//	}, 300*time.Millisecond)
+//
+//	search.Call("g")
+//	search.Call("go")
+//	search.Call("gopher") // only "gopher" is searched, 300ms after this call
+//
+//	defer search.Flush() // run a pending search before returning
+type Debouncer[T any] struct {
+	f            func(T)
+	delay        time.Duration
+	maxWait      time.Duration
+	leading      bool
+	trailing     bool
+	panicHandler func(*PanicError)
+	clock        Clock
+
+	mu         sync.Mutex
+	active     bool // a burst is in progress and timer is armed
+	pending    bool // a trailing call is due
+	arg        T
+	burstStart time.Time
+	timer      Timer
+	generation uint64 // incremented on every re-arm to ignore stale timers
+	panicked   error  // first unhandled panic of a trailing edge, re-raised by the next call
+}
+
+// NewDebouncer returns a Debouncer that calls f with the latest argument once
+// delay has passed without calls. Use WithLeading, WithTrailing and WithMaxWait
+// to select the edges it fires on and to bound how long it may be postponed.
+func NewDebouncer[T any](f func(T), delay time.Duration, opts ...Option) *Debouncer[T] {
+	o := applyOptions(opts)
+	d := &Debouncer[T]{
+		f:            f,
+		delay:        delay,
+		maxWait:      o.maxWait,
+		trailing:     true,
+		panicHandler: o.panicHandler,
+		clock:        o.clock,
+	}
+	if o.hasLeading {
+		d.leading = o.leading
+	}
+	if o.hasTrailing {
+		d.trailing = o.trailing
+	}
+	return d
+}
+
+// NewThrottler returns a Debouncer that calls f at most once per interval: right
+// away on the first call, then with the latest argument at the end of each
+// interval in which calls arrived. Unlike Throttle, the last call of a burst is
+// never lost. WithLeading(false) and WithTrailing(false) disable either edge.
+//
+// Example:
+//
+//	progress := async.NewThrottler(func(pct int) {
+//		fmt.Printf("\r%d%%", pct)
+//	}, 100*time.Millisecond)
+//
+//	for i := range items {
+//		process(items[i])
+//		progress.Call(100 * (i + 1) / len(items))
+//	}
+func NewThrottler[T any](f func(T), interval time.Duration, opts ...Option) *Debouncer[T] {
+	o := applyOptions(opts)
+	d := &Debouncer[T]{
+		f:            f,
+		delay:        interval,
+		maxWait:      interval,
+		leading:      true,
+		trailing:     true,
+		panicHandler: o.panicHandler,
+		clock:        o.clock,
+	}
+	if o.hasLeading {
+		d.leading = o.leading
+	}
+	if o.hasTrailing {
+		d.trailing = o.trailing
+	}
+	return d
+}
+
+// Call records a call with arg, running the function now if it fires on the
+// leading edge and the Debouncer is idle, and otherwise scheduling it.
+func (d *Debouncer[T]) Call(arg T) {
+	d.repanic()
+	d.mu.Lock()
+	now := d.clock.Now()
+	invoke := false
+	if !d.active {
+		d.active = true
+		d.burstStart = now
+		invoke = d.leading
+	}
+	if !invoke && d.trailing {
+		d.pending = true
+		d.arg = arg
+	}
+	d.arm(now.Add(d.delay))
+	d.mu.Unlock()
+
+	if invoke {
+		d.invoke(arg, false)
+	}
+}
+
+// Cancel drops the pending call, if any, and makes the Debouncer idle.
+func (d *Debouncer[T]) Cancel() {
+	d.repanic()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.reset()
+}
+
+// Flush runs the pending call, if any, right away on the calling goroutine and
+// makes the Debouncer idle.
+//
+// Example:
+//
+//	save := async.NewDebouncer(store, time.Second)
+//	defer save.Flush() // do not lose the last edit on shutdown
+func (d *Debouncer[T]) Flush() {
+	d.repanic()
+	d.mu.Lock()
+	pending, arg := d.pending, d.arg
+	d.reset()
+	d.mu.Unlock()
+
+	if pending {
+		d.invoke(arg, false)
+	}
+}
+
+// Pending reports whether a call is waiting to run on the trailing edge.
+func (d *Debouncer[T]) Pending() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pending
+}
+
+// arm schedules the next edge at deadline, or earlier if the burst would
+// otherwise exceed maxWait. d.mu must be held.
+func (d *Debouncer[T]) arm(deadline time.Time) {
+	if d.maxWait > 0 {
+		if limit := d.burstStart.Add(d.maxWait); limit.Before(deadline) {
+			deadline = limit
+		}
+	}
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.generation++
+	generation := d.generation
+	d.timer = d.clock.AfterFunc(deadline.Sub(d.clock.Now()), func() {
+		d.fire(generation)
+	})
+}
+
+// fire handles the trailing edge scheduled by arm. If the function runs, a new
+// burst starts so that it cannot run again within the delay; otherwise the
+// Debouncer becomes idle.
+func (d *Debouncer[T]) fire(generation uint64) {
+	d.mu.Lock()
+	if generation != d.generation {
+		d.mu.Unlock()
+		return
+	}
+
+	pending, arg := d.pending, d.arg
+	if pending {
+		var zero T
+		d.pending = false
+		d.arg = zero
+		now := d.clock.Now()
+		d.burstStart = now
+		d.arm(now.Add(d.delay))
+	} else {
+		d.reset()
+	}
+	d.mu.Unlock()
+
+	if pending {
+		d.invoke(arg, true)
+	}
+}
+
+// reset drops the pending call and stops the timer. d.mu must be held.
+func (d *Debouncer[T]) reset() {
+	var zero T
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.generation++
+	d.active = false
+	d.pending = false
+	d.arg = zero
+}
+
+// invoke runs the function with arg, recovering a panic. Without a panic
+// handler, the panic is re-raised right away, or recorded for the next call if
+// the function runs on its own goroutine.
+func (d *Debouncer[T]) invoke(arg T, own bool) {
+	err := catch(func() error {
+		d.f(arg)
+		return nil
+	}, d.panicHandler)
+	if err == nil || d.panicHandler != nil {
+		return
+	}
+	if !own {
+		panic(err)
+	}
+	d.mu.Lock()
+	if d.panicked == nil {
+		d.panicked = err
+	}
+	d.mu.Unlock()
+}
+
+// repanic re-raises the first unhandled panic of a trailing edge, if any, in
+// the caller's goroutine.
+func (d *Debouncer[T]) repanic() {
+	d.mu.Lock()
+	err := d.panicked
+	d.panicked = nil
+	d.mu.Unlock()
+
+	if err != nil {
+		panic(err)
+	}
+}
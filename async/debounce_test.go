@@ -0,0 +1,333 @@
+package async
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	t.Run("debounces rapid calls", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		calls := 0
+		debounced := Debounce(func() { calls++ }, 50*time.Millisecond, WithClock(clock))
+
+		// Rapid calls - only the last one should execute
+		for i := 0; i < 5; i++ {
+			debounced()
+			clock.Advance(10 * time.Millisecond)
+		}
+		if calls != 0 {
+			t.Errorf("Debounce executed %d times during the burst, want 0", calls)
+		}
+
+		clock.Advance(40 * time.Millisecond)
+		if calls != 1 {
+			t.Errorf("Debounce executed %d times, want 1", calls)
+		}
+	})
+
+	t.Run("executes after delay", func(t *testing.T) {
+		var executed int32
+		debounced := Debounce(func() {
+			atomic.StoreInt32(&executed, 1)
+		}, 30*time.Millisecond)
+
+		debounced()
+
+		// Check immediately - should not be executed yet
+		if atomic.LoadInt32(&executed) != 0 {
+			t.Error("Debounced function executed too early")
+		}
+
+		// Wait for delay and check again
+		time.Sleep(50 * time.Millisecond)
+		if atomic.LoadInt32(&executed) != 1 {
+			t.Error("Debounced function was not executed after delay")
+		}
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	t.Run("measures the interval on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		calls := 0
+		throttled := Throttle(func() { calls++ }, time.Minute, WithClock(clock))
+
+		throttled()
+		clock.Advance(59 * time.Second)
+		throttled()
+		clock.Advance(time.Second)
+		throttled()
+		if calls != 2 {
+			t.Errorf("Throttle executed %d times, want 2", calls)
+		}
+	})
+
+	t.Run("throttles rapid calls", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		calls := 0
+		throttled := Throttle(func() { calls++ }, 50*time.Millisecond, WithClock(clock))
+
+		// Rapid calls
+		for i := 0; i < 5; i++ {
+			throttled()
+			clock.Advance(time.Millisecond)
+		}
+
+		// First call should execute immediately, others should be throttled
+		if calls != 1 {
+			t.Errorf("Throttle executed %d times, want 1", calls)
+		}
+
+		// Wait for throttle period and call again
+		clock.Advance(50 * time.Millisecond)
+		throttled()
+		if calls != 2 {
+			t.Errorf("Throttle executed %d times after delay, want 2", calls)
+		}
+	})
+
+	t.Run("first call executes immediately", func(t *testing.T) {
+		var executed int32
+		throttled := Throttle(func() {
+			atomic.StoreInt32(&executed, 1)
+		}, 100*time.Millisecond)
+
+		throttled()
+
+		if atomic.LoadInt32(&executed) != 1 {
+			t.Error("First throttled call should execute immediately")
+		}
+	})
+}
+
+func TestDebouncePanic(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	handled := make(chan *PanicError, 1)
+	debounced := Debounce(func() {
+		panic("boom")
+	}, time.Millisecond, WithClock(clock), WithPanicHandler(func(pe *PanicError) { handled <- pe }))
+
+	debounced()
+	clock.Advance(time.Millisecond)
+
+	select {
+	case pe := <-handled:
+		if pe.Value != "boom" {
+			t.Errorf("PanicError.Value = %v, want boom", pe.Value)
+		}
+	default:
+		t.Error("panic handler was not called")
+	}
+}
+
+// recorder collects the arguments a Debouncer calls its function with.
+type recorder struct {
+	mu    sync.Mutex
+	calls []int
+}
+
+func (r *recorder) record(v int) {
+	r.mu.Lock()
+	r.calls = append(r.calls, v)
+	r.mu.Unlock()
+}
+
+func (r *recorder) get() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.calls...)
+}
+
+func TestDebouncer(t *testing.T) {
+	t.Run("runs on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var got []int
+		d := NewDebouncer(func(v int) { got = append(got, v) }, time.Second,
+			WithClock(clock), WithMaxWait(3*time.Second))
+
+		for i := 1; i <= 5; i++ {
+			d.Call(i)
+			clock.Advance(900 * time.Millisecond)
+		}
+		// The burst started 4.5s ago, so maxWait fired once, at 3s, with 4.
+		if len(got) != 1 || got[0] != 4 {
+			t.Fatalf("calls = %v, want [4]", got)
+		}
+
+		clock.Advance(time.Second)
+		if len(got) != 2 || got[1] != 5 {
+			t.Errorf("calls = %v, want [4 5]", got)
+		}
+	})
+
+	t.Run("trailing call gets the latest argument", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var r recorder
+		d := NewDebouncer(r.record, 30*time.Millisecond, WithClock(clock))
+
+		for i := 1; i <= 3; i++ {
+			d.Call(i)
+		}
+		if !d.Pending() {
+			t.Error("Pending() = false, want true")
+		}
+		clock.Advance(30 * time.Millisecond)
+
+		if got := r.get(); len(got) != 1 || got[0] != 3 {
+			t.Errorf("calls = %v, want [3]", got)
+		}
+		if d.Pending() {
+			t.Error("Pending() after firing = true, want false")
+		}
+	})
+
+	t.Run("leading edge", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var r recorder
+		d := NewDebouncer(r.record, 30*time.Millisecond, WithClock(clock), WithLeading(true))
+
+		d.Call(1)
+		if got := r.get(); len(got) != 1 || got[0] != 1 {
+			t.Fatalf("calls after first Call = %v, want [1]", got)
+		}
+		d.Call(2)
+		d.Call(3)
+		clock.Advance(30 * time.Millisecond)
+
+		if got := r.get(); len(got) != 2 || got[1] != 3 {
+			t.Errorf("calls = %v, want [1 3]", got)
+		}
+	})
+
+	t.Run("leading edge only", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var r recorder
+		d := NewDebouncer(r.record, 20*time.Millisecond,
+			WithClock(clock), WithLeading(true), WithTrailing(false))
+
+		d.Call(1)
+		d.Call(2)
+		clock.Advance(20 * time.Millisecond)
+		d.Call(3)
+
+		if got := r.get(); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+			t.Errorf("calls = %v, want [1 3]", got)
+		}
+	})
+
+	t.Run("max wait fires during a constant stream", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var r recorder
+		d := NewDebouncer(r.record, 30*time.Millisecond,
+			WithClock(clock), WithMaxWait(50*time.Millisecond))
+
+		for i := 0; i < 15; i++ {
+			d.Call(i)
+			clock.Advance(10 * time.Millisecond)
+		}
+		// Each burst is cut after 50ms, with the last of its five calls.
+		if got := r.get(); !reflect.DeepEqual(got, []int{4, 9, 14}) {
+			t.Errorf("calls during the stream = %v, want [4 9 14]", got)
+		}
+	})
+
+	t.Run("Cancel drops the pending call", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		var r recorder
+		d := NewDebouncer(r.record, 20*time.Millisecond, WithClock(clock))
+
+		d.Call(1)
+		d.Cancel()
+		clock.Advance(time.Minute)
+
+		if got := r.get(); len(got) != 0 {
+			t.Errorf("calls = %v, want none", got)
+		}
+	})
+
+	t.Run("Flush runs the pending call now", func(t *testing.T) {
+		var r recorder
+		d := NewDebouncer(r.record, time.Hour)
+
+		d.Call(1)
+		d.Call(2)
+		d.Flush()
+		if got := r.get(); len(got) != 1 || got[0] != 2 {
+			t.Errorf("calls = %v, want [2]", got)
+		}
+
+		d.Flush()
+		if got := r.get(); len(got) != 1 {
+			t.Errorf("calls after second Flush = %v, want [2]", got)
+		}
+	})
+
+	t.Run("panics go to the handler", func(t *testing.T) {
+		handled := make(chan *PanicError, 1)
+		d := NewDebouncer(func(int) { panic("boom") }, time.Millisecond,
+			WithPanicHandler(func(pe *PanicError) { handled <- pe }))
+
+		d.Call(1)
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Error("panic handler was not called")
+		}
+	})
+
+	// mustPanic calls f and fails unless it panics with a *PanicError.
+	mustPanic := func(t *testing.T, name string, f func()) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			if _, ok := recover().(*PanicError); !ok {
+				t.Errorf("%s did not panic with a *PanicError", name)
+			}
+		}()
+		f()
+	}
+
+	t.Run("without a handler, panics are re-raised", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		d := NewDebouncer(func(int) { panic("boom") }, time.Second, WithClock(clock))
+
+		d.Call(1)
+		clock.Advance(time.Second) // the trailing edge panics on the timer
+		mustPanic(t, "Call() after a trailing panic", func() { d.Call(2) })
+		d.Call(3) // the panic was re-raised once
+
+		mustPanic(t, "Flush()", d.Flush)
+	})
+}
+
+func TestThrottler(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	var r recorder
+	d := NewThrottler(r.record, 40*time.Millisecond, WithClock(clock))
+
+	d.Call(1)
+	d.Call(2)
+	d.Call(3)
+	if got := r.get(); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("calls right away = %v, want [1]", got)
+	}
+
+	clock.Advance(40 * time.Millisecond)
+	if got := r.get(); len(got) != 2 || got[1] != 3 {
+		t.Fatalf("calls after the interval = %v, want [1 3]", got)
+	}
+
+	// The trailing call started a new interval, so this one is delayed too.
+	d.Call(4)
+	if got := r.get(); len(got) != 2 {
+		t.Errorf("calls within the interval = %v, want [1 3]", got)
+	}
+	clock.Advance(40 * time.Millisecond)
+	if got := r.get(); len(got) != 3 || got[2] != 4 {
+		t.Errorf("calls = %v, want [1 3 4]", got)
+	}
+}
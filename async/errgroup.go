@@ -0,0 +1,186 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrGroup is a collection of goroutines working on subtasks that are part of the same overall task.
+// It captures the first error that occurs and, when created with WithContext, cancels the
+// group's context so that the remaining goroutines can stop early.
+//
+// The zero value is a valid group with no concurrency limit that only records the first error.
+// A panic in a function passed to Go is recovered and reported by Wait as a *PanicError.
+//
+// Example:
+//
+//	eg, ctx := async.WithContext(context.Background())
+//	eg.SetLimit(3)
+//	for i := 0; i < 5; i++ {
+//		i := i // capture loop variable
+//		eg.Go(func() error {
+//			return fetch(ctx, i) // ctx is cancelled as soon as one fetch fails
+//		})
+//	}
+//	if err := eg.Wait(); err != nil {
+//		fmt.Printf("Error: %v\n", err)
+//	}
+type ErrGroup struct {
+	cancel func(error)
+
+	wg  sync.WaitGroup
+	sem chan struct{}
+
+	collectAll   bool
+	panicHandler func(*PanicError)
+	hooks        Hooks
+	clock        Clock // nil in a zero ErrGroup, meaning the system clock
+	seq          uint64
+
+	errOnce sync.Once
+	err     error
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewErrGroup creates an ErrGroup configured with the given options.
+// Use WithAllErrors to make Wait report every error instead of only the first one,
+// WithPanicHandler to observe panics recovered from the group's goroutines, and
+// WithHooks to observe each function as it is submitted, started and finished.
+// A function's wait time is the time Go spent blocked on the group's limit; the
+// times reported to the hooks are measured on the clock set with WithClock.
+func NewErrGroup(opts ...Option) *ErrGroup {
+	o := applyOptions(opts)
+	return &ErrGroup{collectAll: o.collectAll, panicHandler: o.panicHandler, hooks: o.hooks, clock: o.clock}
+}
+
+// WithContext returns a new ErrGroup and an associated context derived from ctx.
+// The derived context is cancelled the first time a function passed to Go returns
+// a non-nil error, or when Wait returns, whichever occurs first. The error that
+// triggered the cancellation is available through context.Cause.
+//
+// Example:
+//
+//	eg, ctx := async.WithContext(ctx, async.WithAllErrors())
+//	for _, url := range urls {
+//		url := url
+//		eg.Go(func() error { return download(ctx, url) })
+//	}
+//	err := eg.Wait() // all download errors joined together
+func WithContext(ctx context.Context, opts ...Option) (*ErrGroup, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := NewErrGroup(opts...)
+	g.cancel = cancel
+	return g, ctx
+}
+
+// SetLimit limits the number of active goroutines in this group to at most n.
+// A negative value indicates no limit. Go blocks until a slot is available.
+//
+// The limit must not be modified while any goroutines in the group are active.
+func (g *ErrGroup) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("async: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go starts a goroutine and runs the given function.
+// If the group has a limit, Go blocks until the new goroutine can be added
+// without exceeding it. The first call to return a non-nil error cancels the group.
+func (g *ErrGroup) Go(f func() error) {
+	submitted := g.now()
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(f, submitted)
+}
+
+// TryGo calls the given function in a new goroutine only if the number of active
+// goroutines in the group is currently below the configured limit.
+// The return value reports whether the goroutine was started.
+func (g *ErrGroup) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(f, g.now())
+	return true
+}
+
+// Wait blocks until all goroutines have completed and returns the first error,
+// or every error joined together if the group was created with WithAllErrors.
+func (g *ErrGroup) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	if g.collectAll {
+		return errors.Join(g.errs...)
+	}
+	return g.err
+}
+
+// start runs f in a new goroutine after a limit slot, if any, has been acquired.
+func (g *ErrGroup) start(f func() error, submitted time.Time) {
+	id := atomic.AddUint64(&g.seq, 1)
+	g.hooks.submit(TaskEvent{ID: id})
+
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+
+		started := g.now()
+		wait := started.Sub(submitted)
+		g.hooks.start(TaskEvent{ID: id, Wait: wait})
+
+		err := catch(f, g.panicHandler)
+		if err != nil {
+			g.fail(err)
+		}
+		g.hooks.finish(TaskEvent{ID: id, Wait: wait, Run: g.now().Sub(started), Err: err})
+	}()
+}
+
+// done releases the goroutine's limit slot and marks it as finished.
+func (g *ErrGroup) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// fail records err and cancels the group's context on the first failure.
+func (g *ErrGroup) fail(err error) {
+	if g.collectAll {
+		g.mu.Lock()
+		g.errs = append(g.errs, err)
+		g.mu.Unlock()
+	}
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel(err)
+		}
+	})
+}
+
+// now returns the current time on the group's clock.
+func (g *ErrGroup) now() time.Time {
+	if g.clock == nil {
+		return time.Now()
+	}
+	return g.clock.Now()
+}
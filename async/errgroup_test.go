@@ -0,0 +1,268 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrGroup(t *testing.T) {
+	t.Run("all succeed", func(t *testing.T) {
+		eg := &ErrGroup{}
+
+		for i := 0; i < 5; i++ {
+			eg.Go(func() error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			})
+		}
+
+		err := eg.Wait()
+		if err != nil {
+			t.Errorf("ErrGroup.Wait() = %v, want nil", err)
+		}
+	})
+
+	t.Run("one fails", func(t *testing.T) {
+		eg := &ErrGroup{}
+
+		for i := 0; i < 5; i++ {
+			i := i // capture loop variable
+			eg.Go(func() error {
+				time.Sleep(10 * time.Millisecond)
+				if i == 2 {
+					return fmt.Errorf("error at %d", i)
+				}
+				return nil
+			})
+		}
+
+		err := eg.Wait()
+		if err == nil {
+			t.Error("ErrGroup.Wait() expected error, got nil")
+		}
+	})
+
+	t.Run("no goroutines", func(t *testing.T) {
+		eg := &ErrGroup{}
+		err := eg.Wait()
+
+		if err != nil {
+			t.Errorf("ErrGroup.Wait() with no goroutines = %v, want nil", err)
+		}
+	})
+}
+
+func TestWithContext(t *testing.T) {
+	t.Run("cancels context on first error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		eg, ctx := WithContext(context.Background())
+
+		eg.Go(func() error {
+			return errBoom
+		})
+		eg.Go(func() error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return errors.New("context was not cancelled")
+			}
+		})
+
+		if err := eg.Wait(); err != errBoom {
+			t.Errorf("ErrGroup.Wait() = %v, want %v", err, errBoom)
+		}
+		if cause := context.Cause(ctx); cause != errBoom {
+			t.Errorf("context.Cause() = %v, want %v", cause, errBoom)
+		}
+	})
+
+	t.Run("cancels context when Wait returns", func(t *testing.T) {
+		eg, ctx := WithContext(context.Background())
+		eg.Go(func() error { return nil })
+
+		if err := eg.Wait(); err != nil {
+			t.Errorf("ErrGroup.Wait() = %v, want nil", err)
+		}
+		if ctx.Err() == nil {
+			t.Error("context should be cancelled after Wait returns")
+		}
+	})
+
+	t.Run("collects all errors", func(t *testing.T) {
+		errA := errors.New("a")
+		errB := errors.New("b")
+		eg, _ := WithContext(context.Background(), WithAllErrors())
+
+		eg.Go(func() error { return errA })
+		eg.Go(func() error { return nil })
+		eg.Go(func() error { return errB })
+
+		err := eg.Wait()
+		if !errors.Is(err, errA) || !errors.Is(err, errB) {
+			t.Errorf("ErrGroup.Wait() = %v, want both %v and %v", err, errA, errB)
+		}
+	})
+}
+
+func TestErrGroupLimit(t *testing.T) {
+	t.Run("caps active goroutines", func(t *testing.T) {
+		eg := &ErrGroup{}
+		eg.SetLimit(2)
+
+		var active, peak int64
+		for i := 0; i < 10; i++ {
+			eg.Go(func() error {
+				n := atomic.AddInt64(&active, 1)
+				for {
+					old := atomic.LoadInt64(&peak)
+					if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt64(&active, -1)
+				return nil
+			})
+		}
+
+		if err := eg.Wait(); err != nil {
+			t.Errorf("ErrGroup.Wait() = %v, want nil", err)
+		}
+		if peak > 2 {
+			t.Errorf("peak active goroutines = %d, want <= 2", peak)
+		}
+	})
+
+	t.Run("TryGo reports a full group", func(t *testing.T) {
+		eg := NewErrGroup()
+		eg.SetLimit(1)
+
+		release := make(chan struct{})
+		if !eg.TryGo(func() error { <-release; return nil }) {
+			t.Fatal("TryGo() = false on an empty group, want true")
+		}
+		if eg.TryGo(func() error { return nil }) {
+			t.Error("TryGo() = true on a full group, want false")
+		}
+
+		close(release)
+		if err := eg.Wait(); err != nil {
+			t.Errorf("ErrGroup.Wait() = %v, want nil", err)
+		}
+		if !eg.TryGo(func() error { return nil }) {
+			t.Error("TryGo() = false after the group drained, want true")
+		}
+		eg.Wait()
+	})
+
+	t.Run("negative limit removes the cap", func(t *testing.T) {
+		eg := NewErrGroup()
+		eg.SetLimit(1)
+		eg.SetLimit(-1)
+
+		release := make(chan struct{})
+		for i := 0; i < 3; i++ {
+			if !eg.TryGo(func() error { <-release; return nil }) {
+				t.Fatalf("TryGo() = false without a limit, want true")
+			}
+		}
+		close(release)
+		if err := eg.Wait(); err != nil {
+			t.Errorf("ErrGroup.Wait() = %v, want nil", err)
+		}
+	})
+}
+
+func TestErrGroupPanic(t *testing.T) {
+	t.Run("reports panic as error", func(t *testing.T) {
+		eg, ctx := WithContext(context.Background())
+		eg.Go(func() error {
+			panic("boom")
+		})
+
+		err := eg.Wait()
+		var pe *PanicError
+		if !errors.As(err, &pe) || pe.Value != "boom" {
+			t.Errorf("ErrGroup.Wait() = %v, want *PanicError with value boom", err)
+		}
+		if ctx.Err() == nil {
+			t.Error("panic should cancel the group context")
+		}
+	})
+
+	t.Run("zero value recovers too", func(t *testing.T) {
+		eg := &ErrGroup{}
+		eg.Go(func() error {
+			var m map[string]int
+			m["x"] = 1
+			return nil
+		})
+
+		var pe *PanicError
+		if err := eg.Wait(); !errors.As(err, &pe) {
+			t.Errorf("ErrGroup.Wait() = %v, want *PanicError", err)
+		}
+	})
+
+	t.Run("calls panic handler", func(t *testing.T) {
+		var handled int64
+		eg := NewErrGroup(WithPanicHandler(func(*PanicError) {
+			atomic.AddInt64(&handled, 1)
+		}))
+		eg.Go(func() error { panic("boom") })
+		eg.Wait()
+
+		if handled != 1 {
+			t.Errorf("panic handler called %d times, want 1", handled)
+		}
+	})
+}
+
+func TestErrGroupHooks(t *testing.T) {
+	errBoom := errors.New("boom")
+	var starts, finishes int64
+	var failed error
+
+	eg := NewErrGroup(WithHooks(Hooks{
+		OnStart: func(TaskEvent) { atomic.AddInt64(&starts, 1) },
+		OnFinish: func(ev TaskEvent) {
+			atomic.AddInt64(&finishes, 1)
+			if ev.Err != nil {
+				failed = ev.Err
+			}
+		},
+	}))
+	eg.SetLimit(1)
+	eg.Go(func() error { return nil })
+	eg.Go(func() error { return errBoom })
+	eg.Wait()
+
+	if starts != 2 || finishes != 2 {
+		t.Errorf("hooks called start=%d finish=%d, want 2 each", starts, finishes)
+	}
+	if failed != errBoom {
+		t.Errorf("OnFinish error = %v, want %v", failed, errBoom)
+	}
+}
+
+func TestErrGroupHooksClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	var run time.Duration
+	eg := NewErrGroup(WithClock(clock), WithHooks(Hooks{
+		OnFinish: func(ev TaskEvent) { run = ev.Run },
+	}))
+	eg.Go(func() error {
+		clock.Advance(time.Second)
+		return nil
+	})
+	eg.Wait()
+
+	if run != time.Second {
+		t.Errorf("OnFinish Run = %v, want 1s on the fake clock", run)
+	}
+}
@@ -0,0 +1,431 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrBusClosed is returned when publishing to or subscribing on a closed EventBus.
+var ErrBusClosed = errors.New("async: event bus is closed")
+
+// defaultSubscriberQueue is the queue capacity of an asynchronous subscriber
+// when none is configured.
+const defaultSubscriberQueue = 64
+
+// Topic is a typed event topic: publishing and subscribing through the same
+// Topic value guarantees that handlers receive the type that is published.
+// Topic names are dot-separated segments, such as "orders.created".
+//
+// Example:
+//
+//	var OrderCreated = async.NewTopic[Order]("orders.created")
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic returns the topic with the given name for events of type T.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Event is an event delivered to a subscriber.
+type Event[T any] struct {
+	// Topic is the name of the topic the event was published to.
+	Topic string
+	// Payload is the published value.
+	Payload T
+}
+
+// EventBusConfig configures an EventBus.
+type EventBusConfig struct {
+	// Pool, if set, delivers events asynchronously: Publish puts each event in
+	// the queue of every matching subscriber and returns, and the pool's workers
+	// run the handlers. Each subscriber still sees its events one at a time, in
+	// publishing order. Publish never waits for room in the pool's queue: while
+	// it is full, a subscriber's events are delivered on a goroutine of its own.
+	// If nil, Publish runs the handlers itself.
+	Pool *Pool
+	// QueueCapacity is the size of the queue of each asynchronous subscriber,
+	// unless it subscribes with WithQueueCapacity. Defaults to 64.
+	QueueCapacity int
+	// Overflow is the policy applied when the queue of an asynchronous
+	// subscriber is full, unless it subscribes with WithOverflow. The default is
+	// OverflowBlock.
+	Overflow OverflowPolicy
+	// PanicHandler is called with every panic recovered from a handler. If it is
+	// nil, a panic is re-raised as a *PanicError: by Publish, once the event has
+	// been delivered to the other subscribers, with synchronous delivery, and by
+	// Close, once the queues have been drained, with asynchronous delivery.
+	PanicHandler func(*PanicError)
+}
+
+// EventBus is an in-process publish/subscribe bus that decouples the modules
+// of a program: publishers send typed events to named topics without knowing
+// who handles them. Subscribers may use wildcard patterns; delivery is
+// synchronous, or asynchronous through a Pool with a bounded queue per
+// subscriber. It is safe for concurrent use.
+//
+// Example:
+//
+//	var OrderCreated = async.NewTopic[Order]("orders.created")
+//
+//	bus := async.NewEventBus(async.EventBusConfig{Pool: async.NewPool(4)})
+//	sub, _ := async.Subscribe(bus, OrderCreated, func(ctx context.Context, e async.Event[Order]) {
+//		mailer.SendConfirmation(ctx, e.Payload)
+//	}, async.WithOverflow(async.OverflowDropOldest))
+//	defer sub.Unsubscribe()
+//
+//	err := async.Publish(ctx, bus, OrderCreated, order)
+//
+//	// On shutdown, deliver the events still queued.
+//	bus.Close(shutdownCtx)
+type EventBus struct {
+	cfg EventBusConfig
+
+	mu     sync.RWMutex
+	subs   []*Subscription // copied on write, so that publishers can use a snapshot
+	nextID uint64
+	closed bool
+
+	// panicked is the first unhandled panic of an asynchronous handler, which
+	// Close re-raises.
+	panicked error
+
+	// pending counts the publications in progress and the queued events, which
+	// Close waits for.
+	pending sync.WaitGroup
+}
+
+// NewEventBus returns an EventBus with the given configuration.
+func NewEventBus(cfg EventBusConfig) *EventBus {
+	if cfg.QueueCapacity <= 0 {
+		cfg.QueueCapacity = defaultSubscriberQueue
+	}
+	return &EventBus{cfg: cfg}
+}
+
+// Subscription is a subscriber registered on an EventBus.
+type Subscription struct {
+	bus     *EventBus
+	pattern string
+	match   []string // segments of pattern
+	deliver func(ctx context.Context, topic string, payload any)
+
+	// Queue of an asynchronous subscriber.
+	capacity int
+	overflow OverflowPolicy
+	dropped  uint64 // atomic
+
+	mu       sync.Mutex
+	queue    []queuedEvent
+	space    chan struct{} // closed and replaced when an event leaves the queue
+	draining bool          // a pool task is delivering the queue
+	stopped  bool
+}
+
+// queuedEvent is an event waiting in the queue of an asynchronous subscriber.
+type queuedEvent struct {
+	ctx     context.Context
+	topic   string
+	payload any
+}
+
+// Publish sends payload to every subscriber of topic, including those whose
+// pattern matches it. With synchronous delivery it returns once the handlers
+// have run; with asynchronous delivery it returns once the event is queued for
+// each subscriber, which may block under OverflowBlock until there is room or
+// ctx is done, in which case it returns ctx.Err() and the subscribers that
+// were not reached yet do not receive the event. Under OverflowError it returns
+// ErrQueueFull at the first full subscriber in the same way. It returns
+// ErrBusClosed once Close has been called.
+//
+// The handlers receive ctx, detached from its cancellation when they run
+// asynchronously. Handler panics are dealt with as described for
+// EventBusConfig.PanicHandler.
+func Publish[T any](ctx context.Context, bus *EventBus, topic Topic[T], payload T) error {
+	return bus.publish(ctx, topic.name, payload)
+}
+
+// Subscribe registers handler for the events published to topic.
+// For asynchronous buses, WithQueueCapacity and WithOverflow override the
+// bus's queue settings for this subscriber.
+func Subscribe[T any](bus *EventBus, topic Topic[T], handler func(context.Context, Event[T]), opts ...Option) (*Subscription, error) {
+	return SubscribePattern(bus, topic.name, handler, opts...)
+}
+
+// SubscribePattern registers handler for the events of type T published to the
+// topics matching pattern; events of other types published to those topics are
+// skipped. In a pattern, the segment "*" matches any single segment and a final
+// "**" matches one or more segments, so that "orders.*" matches
+// "orders.created" and "orders.**" also matches "orders.eu.created".
+//
+// Example:
+//
+//	// Audit every order event, whatever its type.
+//	async.SubscribePattern(bus, "orders.**", func(ctx context.Context, e async.Event[any]) {
+//		audit.Record(ctx, e.Topic, e.Payload)
+//	})
+func SubscribePattern[T any](bus *EventBus, pattern string, handler func(context.Context, Event[T]), opts ...Option) (*Subscription, error) {
+	segments := strings.Split(pattern, ".")
+	for i, seg := range segments {
+		if seg == "" || (seg == "**" && i != len(segments)-1) || (strings.Contains(seg, "*") && seg != "*" && seg != "**") {
+			return nil, fmt.Errorf("async: invalid topic pattern %q", pattern)
+		}
+	}
+
+	o := applyOptions(opts)
+	s := &Subscription{
+		bus:      bus,
+		pattern:  pattern,
+		match:    segments,
+		capacity: bus.cfg.QueueCapacity,
+		overflow: bus.cfg.Overflow,
+		space:    make(chan struct{}),
+		deliver: func(ctx context.Context, topic string, payload any) {
+			if v, ok := payload.(T); ok {
+				handler(ctx, Event[T]{Topic: topic, Payload: v})
+			}
+		},
+	}
+	if o.queueCapacity > 0 {
+		s.capacity = o.queueCapacity
+	}
+	if o.hasOverflow {
+		s.overflow = o.overflow
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return nil, ErrBusClosed
+	}
+	subs := make([]*Subscription, len(bus.subs), len(bus.subs)+1)
+	copy(subs, bus.subs)
+	bus.subs = append(subs, s)
+	return s, nil
+}
+
+// Close stops the bus from accepting events and subscribers, then waits until
+// the publications in progress have completed and the queued events have been
+// delivered, or until ctx is done, in which case it returns ctx.Err().
+// If a handler panicked during asynchronous delivery and there is no
+// PanicHandler, Close then re-raises the first panic as a *PanicError.
+// Close does not close the Pool.
+func (bus *EventBus) Close(ctx context.Context) error {
+	bus.mu.Lock()
+	bus.closed = true
+	bus.mu.Unlock()
+
+	if err := waitCtx(ctx, func() error {
+		bus.pending.Wait()
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	bus.mu.Lock()
+	err := bus.panicked
+	bus.panicked = nil
+	bus.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// publish delivers payload to the subscribers matching topic.
+func (bus *EventBus) publish(ctx context.Context, topic string, payload any) error {
+	if topic == "" || strings.Contains(topic, "*") || strings.Contains(topic, "..") ||
+		strings.HasPrefix(topic, ".") || strings.HasSuffix(topic, ".") {
+		return fmt.Errorf("async: invalid topic %q", topic)
+	}
+
+	bus.mu.RLock()
+	if bus.closed {
+		bus.mu.RUnlock()
+		return ErrBusClosed
+	}
+	bus.pending.Add(1)
+	subs := bus.subs
+	bus.mu.RUnlock()
+	defer bus.pending.Done()
+
+	var panicked error
+	segments := strings.Split(topic, ".")
+	for _, s := range subs {
+		if !matchTopic(s.match, segments) {
+			continue
+		}
+		if bus.cfg.Pool == nil {
+			if err := s.invoke(ctx, topic, payload); err != nil && panicked == nil {
+				panicked = err
+			}
+			continue
+		}
+		if err := s.enqueue(ctx, queuedEvent{ctx: context.WithoutCancel(ctx), topic: topic, payload: payload}); err != nil {
+			return err
+		}
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+	return nil
+}
+
+// matchTopic reports whether the segments of a topic match those of a pattern.
+func matchTopic(pattern, topic []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			return len(topic) > i
+		}
+		if i >= len(topic) || (p != "*" && p != topic[i]) {
+			return false
+		}
+	}
+	return len(topic) == len(pattern)
+}
+
+// Unsubscribe removes the subscription from the bus. Its queued events are
+// discarded; a handler already running is not interrupted.
+func (s *Subscription) Unsubscribe() {
+	bus := s.bus
+	bus.mu.Lock()
+	subs := make([]*Subscription, 0, len(bus.subs))
+	for _, other := range bus.subs {
+		if other != s {
+			subs = append(subs, other)
+		}
+	}
+	bus.subs = subs
+	bus.mu.Unlock()
+
+	s.mu.Lock()
+	s.stopped = true
+	discarded := len(s.queue)
+	s.queue = nil
+	close(s.space)
+	s.space = make(chan struct{})
+	s.mu.Unlock()
+
+	for i := 0; i < discarded; i++ {
+		bus.pending.Done()
+	}
+}
+
+// Pattern returns the topic or pattern the subscription was made with.
+func (s *Subscription) Pattern() string {
+	return s.pattern
+}
+
+// Len returns the number of events waiting in the subscriber's queue.
+func (s *Subscription) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Dropped returns the number of events the subscriber lost to its overflow policy.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// enqueue adds ev to the queue according to the overflow policy, and starts a
+// pool task to deliver the queue if none is running.
+func (s *Subscription) enqueue(ctx context.Context, ev queuedEvent) error {
+	s.mu.Lock()
+	for len(s.queue) >= s.capacity && !s.stopped {
+		switch s.overflow {
+		case OverflowDropNewest:
+			s.mu.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			return nil
+		case OverflowDropOldest:
+			s.queue[0] = queuedEvent{}
+			s.queue = s.queue[1:]
+			atomic.AddUint64(&s.dropped, 1)
+			s.bus.pending.Done()
+		case OverflowError:
+			s.mu.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			return ErrQueueFull
+		default:
+			space := s.space
+			s.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			s.mu.Lock()
+		}
+	}
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.queue = append(s.queue, ev)
+	s.bus.pending.Add(1)
+	start := !s.draining
+	s.draining = true
+	s.mu.Unlock()
+
+	if start {
+		// Never wait for room in the pool: a handler publishing while every
+		// worker is busy would deadlock the bus. If the pool's queue is full or
+		// the pool is closed, deliver on a goroutine of our own instead.
+		if err := s.bus.cfg.Pool.TrySubmit(s.drain); err != nil {
+			go s.drain()
+		}
+	}
+	return nil
+}
+
+// drain delivers the queued events one at a time until the queue is empty.
+func (s *Subscription) drain() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.stopped {
+			s.draining = false
+			s.mu.Unlock()
+			return
+		}
+		ev := s.queue[0]
+		s.queue[0] = queuedEvent{}
+		s.queue = s.queue[1:]
+		close(s.space)
+		s.space = make(chan struct{})
+		s.mu.Unlock()
+
+		if err := s.invoke(ev.ctx, ev.topic, ev.payload); err != nil {
+			s.bus.mu.Lock()
+			if s.bus.panicked == nil {
+				s.bus.panicked = err
+			}
+			s.bus.mu.Unlock()
+		}
+		s.bus.pending.Done()
+	}
+}
+
+// invoke runs the handler, recovering a panic. It returns the *PanicError
+// when there is no PanicHandler to hand it to.
+func (s *Subscription) invoke(ctx context.Context, topic string, payload any) error {
+	err := catch(func() error {
+		s.deliver(ctx, topic, payload)
+		return nil
+	}, s.bus.cfg.PanicHandler)
+	if s.bus.cfg.PanicHandler != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,365 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	created := NewTopic[string]("orders.created")
+	shipped := NewTopic[string]("orders.eu.shipped")
+	count := NewTopic[int]("orders.count")
+
+	t.Run("synchronous delivery to typed and wildcard subscribers", func(t *testing.T) {
+		bus := NewEventBus(EventBusConfig{})
+		var got []string
+		record := func(prefix string) func(context.Context, Event[string]) {
+			return func(_ context.Context, e Event[string]) {
+				got = append(got, prefix+":"+e.Topic+"="+e.Payload)
+			}
+		}
+		if _, err := Subscribe(bus, created, record("exact")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := SubscribePattern(bus, "orders.*", record("star")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := SubscribePattern(bus, "orders.**", record("rest")); err != nil {
+			t.Fatal(err)
+		}
+
+		_ = Publish(context.Background(), bus, created, "a")
+		_ = Publish(context.Background(), bus, shipped, "b")
+		_ = Publish(context.Background(), bus, count, 3) // not a string
+
+		want := []string{
+			"exact:orders.created=a", "star:orders.created=a", "rest:orders.created=a",
+			"rest:orders.eu.shipped=b",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("delivered %v, want %v", got, want)
+		}
+	})
+
+	t.Run("matchTopic", func(t *testing.T) {
+		tests := []struct {
+			pattern, topic string
+			want           bool
+		}{
+			{"a.b", "a.b", true},
+			{"a.b", "a.c", false},
+			{"a.*", "a.b", true},
+			{"a.*", "a.b.c", false},
+			{"*.b", "a.b", true},
+			{"a.**", "a.b.c", true},
+			{"a.**", "a", false},
+			{"**", "a", true},
+		}
+		for _, tt := range tests {
+			if got := matchTopic(strings.Split(tt.pattern, "."), strings.Split(tt.topic, ".")); got != tt.want {
+				t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("invalid topics and patterns", func(t *testing.T) {
+		bus := NewEventBus(EventBusConfig{})
+		for _, pattern := range []string{"", "a..b", "a.**.b", "a.b*"} {
+			if _, err := SubscribePattern(bus, pattern, func(context.Context, Event[any]) {}); err == nil {
+				t.Errorf("SubscribePattern(%q) succeeded, want an error", pattern)
+			}
+		}
+		if err := Publish(context.Background(), bus, NewTopic[int]("a.*"), 1); err == nil {
+			t.Error("Publish to a wildcard topic succeeded, want an error")
+		}
+	})
+
+	t.Run("unsubscribe stops delivery", func(t *testing.T) {
+		bus := NewEventBus(EventBusConfig{})
+		var n int
+		sub, _ := Subscribe(bus, count, func(context.Context, Event[int]) { n++ })
+		_ = Publish(context.Background(), bus, count, 1)
+		sub.Unsubscribe()
+		_ = Publish(context.Background(), bus, count, 2)
+		if n != 1 {
+			t.Errorf("handler called %d times, want 1", n)
+		}
+	})
+
+	t.Run("asynchronous delivery keeps per-subscriber order", func(t *testing.T) {
+		pool := NewPool(4)
+		defer pool.Close()
+		bus := NewEventBus(EventBusConfig{Pool: pool})
+		var mu sync.Mutex
+		got := map[string][]int{}
+		for _, name := range []string{"a", "b"} {
+			name := name
+			_, _ = Subscribe(bus, count, func(_ context.Context, e Event[int]) {
+				mu.Lock()
+				got[name] = append(got[name], e.Payload)
+				mu.Unlock()
+			})
+		}
+		for i := 0; i < 100; i++ {
+			if err := Publish(context.Background(), bus, count, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := bus.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"a", "b"} {
+			if len(got[name]) != 100 {
+				t.Fatalf("subscriber %s got %d events, want 100", name, len(got[name]))
+			}
+			for i, v := range got[name] {
+				if v != i {
+					t.Fatalf("subscriber %s got %v, want events in publishing order", name, got[name])
+				}
+			}
+		}
+	})
+
+	// overflow subscribes a blocked handler with a queue of 2, publishes 0 to
+	// 4 and returns the payloads the handler eventually receives.
+	overflow := func(t *testing.T, policy OverflowPolicy) ([]int, *Subscription) {
+		pool := NewPool(1)
+		t.Cleanup(pool.Close)
+		bus := NewEventBus(EventBusConfig{Pool: pool})
+		started, release := make(chan struct{}), make(chan struct{})
+		var got []int
+		sub, _ := Subscribe(bus, count, func(_ context.Context, e Event[int]) {
+			if e.Payload == 0 {
+				close(started)
+				<-release
+			}
+			got = append(got, e.Payload)
+		}, WithQueueCapacity(2), WithOverflow(policy))
+
+		_ = Publish(context.Background(), bus, count, 0)
+		<-started
+		for i := 1; i <= 4; i++ {
+			_ = Publish(context.Background(), bus, count, i)
+		}
+		close(release)
+		if err := bus.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return got, sub
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		got, sub := overflow(t, OverflowDropNewest)
+		if want := []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("delivered %v, want %v", got, want)
+		}
+		if sub.Dropped() != 2 {
+			t.Errorf("Dropped() = %d, want 2", sub.Dropped())
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		got, sub := overflow(t, OverflowDropOldest)
+		if want := []int{0, 3, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("delivered %v, want %v", got, want)
+		}
+		if sub.Dropped() != 2 {
+			t.Errorf("Dropped() = %d, want 2", sub.Dropped())
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		got, sub := overflow(t, OverflowError)
+		if want := []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("delivered %v, want %v", got, want)
+		}
+		if sub.Dropped() != 2 {
+			t.Errorf("Dropped() = %d, want 2", sub.Dropped())
+		}
+	})
+
+	t.Run("block waits for room or the context", func(t *testing.T) {
+		pool := NewPool(1)
+		defer pool.Close()
+		bus := NewEventBus(EventBusConfig{Pool: pool, QueueCapacity: 1})
+		started, release := make(chan struct{}), make(chan struct{})
+		var got []int
+		sub, _ := Subscribe(bus, count, func(_ context.Context, e Event[int]) {
+			if e.Payload == 0 {
+				close(started)
+				<-release
+			}
+			got = append(got, e.Payload)
+		})
+		_ = Publish(context.Background(), bus, count, 0)
+		<-started
+		_ = Publish(context.Background(), bus, count, 1) // fills the queue
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := Publish(ctx, bus, count, 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Publish() error = %v, want DeadlineExceeded", err)
+		}
+
+		errc := make(chan error, 1)
+		go func() { errc <- Publish(context.Background(), bus, count, 3) }()
+		for sub.Len() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		if err := <-errc; err != nil {
+			t.Errorf("Publish() error = %v, want nil once there is room", err)
+		}
+		_ = bus.Close(context.Background())
+		if want := []int{0, 1, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("delivered %v, want %v", got, want)
+		}
+	})
+
+	t.Run("a saturated pool does not block publishers", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(1))
+		release := make(chan struct{})
+		defer func() {
+			close(release)
+			pool.Close()
+		}()
+		busy := make(chan struct{})
+		_ = pool.Submit(func() {
+			close(busy)
+			<-release
+		})
+		<-busy
+		_ = pool.Submit(func() { <-release }) // fills the pool's queue
+
+		bus := NewEventBus(EventBusConfig{Pool: pool, Overflow: OverflowDropNewest})
+		delivered := make(chan int, 2)
+		_, _ = Subscribe(bus, count, func(ctx context.Context, e Event[int]) {
+			if e.Payload == 1 {
+				// Publishing from a handler while the pool is saturated.
+				_ = Publish(ctx, bus, count, 2)
+			}
+			delivered <- e.Payload
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := Publish(ctx, bus, count, 1); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		for _, want := range []int{1, 2} {
+			select {
+			case got := <-delivered:
+				if got != want {
+					t.Errorf("delivered %d, want %d", got, want)
+				}
+			case <-ctx.Done():
+				t.Fatalf("event %d not delivered while the pool is saturated", want)
+			}
+		}
+	})
+
+	t.Run("close drains pending events and rejects new ones", func(t *testing.T) {
+		pool := NewPool(1)
+		defer pool.Close()
+		bus := NewEventBus(EventBusConfig{Pool: pool})
+		release := make(chan struct{})
+		var n int
+		_, _ = Subscribe(bus, count, func(context.Context, Event[int]) {
+			<-release
+			n++
+		})
+		for i := 0; i < 3; i++ {
+			_ = Publish(context.Background(), bus, count, i)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := bus.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Close() error = %v, want DeadlineExceeded while events are pending", err)
+		}
+		if err := Publish(context.Background(), bus, count, 3); !errors.Is(err, ErrBusClosed) {
+			t.Errorf("Publish() error = %v, want ErrBusClosed", err)
+		}
+		if _, err := Subscribe(bus, count, func(context.Context, Event[int]) {}); !errors.Is(err, ErrBusClosed) {
+			t.Errorf("Subscribe() error = %v, want ErrBusClosed", err)
+		}
+
+		close(release)
+		if err := bus.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("handler called %d times, want the 3 pending events", n)
+		}
+	})
+
+	t.Run("handler panics are recovered", func(t *testing.T) {
+		var panics []any
+		bus := NewEventBus(EventBusConfig{PanicHandler: func(pe *PanicError) {
+			panics = append(panics, pe.Value)
+		}})
+		var after bool
+		_, _ = Subscribe(bus, count, func(context.Context, Event[int]) { panic("boom") })
+		_, _ = Subscribe(bus, count, func(context.Context, Event[int]) { after = true })
+		if err := Publish(context.Background(), bus, count, 1); err != nil {
+			t.Fatal(err)
+		}
+		if !after || len(panics) != 1 || panics[0] != "boom" {
+			t.Errorf("after = %v, panics = %v, want later subscribers served and one panic", after, panics)
+		}
+	})
+
+	t.Run("without a handler, Publish re-raises a synchronous panic", func(t *testing.T) {
+		bus := NewEventBus(EventBusConfig{})
+		var after bool
+		_, _ = Subscribe(bus, count, func(context.Context, Event[int]) { panic("boom") })
+		_, _ = Subscribe(bus, count, func(context.Context, Event[int]) { after = true })
+
+		func() {
+			defer func() {
+				if pe, ok := recover().(*PanicError); !ok || pe.Value != "boom" {
+					t.Errorf("Publish() did not re-raise the handler panic as a *PanicError")
+				}
+			}()
+			_ = Publish(context.Background(), bus, count, 1)
+		}()
+		if !after {
+			t.Error("later subscriber not served before the panic was re-raised")
+		}
+		if err := bus.Close(context.Background()); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	t.Run("without a handler, Close re-raises an asynchronous panic", func(t *testing.T) {
+		pool := NewPool(1)
+		defer pool.Close()
+		bus := NewEventBus(EventBusConfig{Pool: pool})
+		var delivered []int
+		_, _ = Subscribe(bus, count, func(_ context.Context, e Event[int]) {
+			if e.Payload == 1 {
+				panic("boom")
+			}
+			delivered = append(delivered, e.Payload)
+		})
+		for i := 1; i <= 3; i++ {
+			_ = Publish(context.Background(), bus, count, i)
+		}
+
+		defer func() {
+			if pe, ok := recover().(*PanicError); !ok || pe.Value != "boom" {
+				t.Errorf("Close() did not re-raise the handler panic as a *PanicError")
+			}
+			if !reflect.DeepEqual(delivered, []int{2, 3}) {
+				t.Errorf("delivered %v, want the events after the panic too", delivered)
+			}
+		}()
+		_ = bus.Close(context.Background())
+	})
+}
@@ -0,0 +1,272 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoFutures is the error of the future returned by Any or Race when called without futures.
+var ErrNoFutures = errors.New("async: no futures given")
+
+// Future holds the result of an asynchronous computation that will be available later.
+// A Future is completed exactly once and can be awaited by any number of goroutines.
+// Futures are created by Go, GoOn, TypedPool.Submit and the combinators in this file.
+//
+// Example:
+//
+//	future := async.Go(func() (User, error) { return loadUser(id) })
+//	select {
+//	case <-future.Done():
+//		value, err := future.Await(ctx)
+//		fmt.Println(value, err)
+//	case <-time.After(time.Second):
+//		fmt.Println("still running")
+//	}
+type Future[T any] struct {
+	done  chan struct{}
+	once  sync.Once
+	value T
+	err   error
+}
+
+// Result is the outcome of a completed Future, as reported by AllSettled.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// Go runs f in a new goroutine and returns a Future for its result.
+// A panic in f completes the future with a *PanicError.
+//
+// Example:
+//
+//	user := async.Go(func() (User, error) { return loadUser(id) })
+//	orders := async.Go(func() ([]Order, error) { return loadOrders(id) })
+//	u, err := user.Await(ctx)
+//	o, err := orders.Await(ctx)
+func Go[T any](f func() (T, error), opts ...Option) *Future[T] {
+	o := applyOptions(opts)
+	future := newFuture[T]()
+	go future.run(f, o.panicHandler)
+	return future
+}
+
+// GoOn runs f on pool instead of a new goroutine and returns a Future for its result,
+// so that the number of concurrently running computations stays bounded.
+// Submission blocks while the pool's queue is full; it fails with ctx.Err() if ctx
+// is done first and with ErrPoolClosed if the pool is closed.
+// A panic in f completes the future with a *PanicError.
+//
+// Example:
+//
+//	pool := async.NewPool(8)
+//	defer pool.Close()
+//	future, err := async.GoOn(ctx, pool, func() (Page, error) { return fetch(url) })
+func GoOn[T any](ctx context.Context, pool *Pool, f func() (T, error)) (*Future[T], error) {
+	future := newFuture[T]()
+	err := pool.SubmitCtx(ctx, func() {
+		future.run(f, pool.panicHandler)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return future, nil
+}
+
+// Then returns a Future that applies fn to the value of f once f succeeds.
+// If f fails, the returned future fails with the same error and fn is not called.
+//
+// Example:
+//
+//	name := async.Then(user, func(u User) (string, error) { return u.Name, nil })
+func Then[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	go func() {
+		<-f.done
+		if f.err != nil {
+			var zero U
+			next.complete(zero, f.err)
+			return
+		}
+		next.run(func() (U, error) { return fn(f.value) }, nil)
+	}()
+	return next
+}
+
+// All returns a Future that completes with the values of all futures, in order,
+// once every one of them has succeeded. It fails as soon as any future fails.
+//
+// Example:
+//
+//	pages, err := async.All(async.Go(fetchA), async.Go(fetchB)).Await(ctx)
+func All[T any](futures ...*Future[T]) *Future[[]T] {
+	all := newFuture[[]T]()
+	values := make([]T, len(futures))
+	remaining := len(futures)
+	if remaining == 0 {
+		all.complete(values, nil)
+		return all
+	}
+
+	var mu sync.Mutex
+	for i, f := range futures {
+		i, f := i, f
+		go func() {
+			<-f.done
+			if f.err != nil {
+				all.complete(nil, f.err)
+				return
+			}
+
+			mu.Lock()
+			values[i] = f.value
+			remaining--
+			last := remaining == 0
+			mu.Unlock()
+			if last {
+				all.complete(values, nil)
+			}
+		}()
+	}
+	return all
+}
+
+// AllSettled returns a Future that completes once every future has completed,
+// with the value and error of each one, in order. It never fails.
+//
+// Example:
+//
+//	results, _ := async.AllSettled(futures...).Await(ctx)
+//	for _, r := range results {
+//		if r.Err != nil {
+//			log.Println(r.Err)
+//		}
+//	}
+func AllSettled[T any](futures ...*Future[T]) *Future[[]Result[T]] {
+	settled := newFuture[[]Result[T]]()
+	go func() {
+		results := make([]Result[T], len(futures))
+		for i, f := range futures {
+			<-f.done
+			results[i] = Result[T]{Value: f.value, Err: f.err}
+		}
+		settled.complete(results, nil)
+	}()
+	return settled
+}
+
+// Any returns a Future that completes with the value of the first future to
+// succeed. If every future fails, it fails with all their errors joined together.
+//
+// Example:
+//
+//	// Ask every replica, use whichever answers first.
+//	value, err := async.Any(async.Go(askA), async.Go(askB)).Await(ctx)
+func Any[T any](futures ...*Future[T]) *Future[T] {
+	first := newFuture[T]()
+	if len(futures) == 0 {
+		var zero T
+		first.complete(zero, ErrNoFutures)
+		return first
+	}
+
+	errs := make([]error, len(futures))
+	remaining := len(futures)
+	var mu sync.Mutex
+	for i, f := range futures {
+		i, f := i, f
+		go func() {
+			<-f.done
+			if f.err == nil {
+				first.complete(f.value, nil)
+				return
+			}
+
+			mu.Lock()
+			errs[i] = f.err
+			remaining--
+			last := remaining == 0
+			mu.Unlock()
+			if last {
+				var zero T
+				first.complete(zero, errors.Join(errs...))
+			}
+		}()
+	}
+	return first
+}
+
+// Race returns a Future that completes with the result of the first future to
+// complete, whether it succeeded or failed.
+//
+// Example:
+//
+//	value, err := async.Race(async.Go(query), timeoutFuture).Await(ctx)
+func Race[T any](futures ...*Future[T]) *Future[T] {
+	first := newFuture[T]()
+	if len(futures) == 0 {
+		var zero T
+		first.complete(zero, ErrNoFutures)
+		return first
+	}
+
+	for _, f := range futures {
+		f := f
+		go func() {
+			<-f.done
+			first.complete(f.value, f.err)
+		}()
+	}
+	return first
+}
+
+// newFuture returns a Future that has not been completed yet.
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// complete stores the result and releases everyone waiting on the future.
+// Only the first call has an effect.
+func (f *Future[T]) complete(value T, err error) {
+	f.once.Do(func() {
+		f.value = value
+		f.err = err
+		close(f.done)
+	})
+}
+
+// run calls fn and completes the future with its result, converting a panic
+// into a *PanicError.
+func (f *Future[T]) run(fn func() (T, error), handler func(*PanicError)) {
+	var value T
+	err := catch(func() (err error) {
+		value, err = fn()
+		return err
+	}, handler)
+	f.complete(value, err)
+}
+
+// Done returns a channel that is closed once the future's result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await blocks until the future completes or ctx is done, and returns the
+// computation's value and error. If ctx is done first, Await returns ctx.Err();
+// the computation itself keeps running.
+func (f *Future[T]) Await(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	default:
+	}
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
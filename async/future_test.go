@@ -0,0 +1,243 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFuture(t *testing.T) {
+	t.Run("await returns completed value", func(t *testing.T) {
+		f := newFuture[string]()
+		go f.complete("done", nil)
+
+		got, err := f.Await(context.Background())
+		if err != nil || got != "done" {
+			t.Errorf("Await() = %q, %v, want \"done\", nil", got, err)
+		}
+	})
+
+	t.Run("await returns completed error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		f := newFuture[int]()
+		f.complete(0, errBoom)
+
+		if _, err := f.Await(context.Background()); err != errBoom {
+			t.Errorf("Await() error = %v, want %v", err, errBoom)
+		}
+	})
+
+	t.Run("await honours context", func(t *testing.T) {
+		f := newFuture[int]()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := f.Await(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Await() error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("done channel closes on completion", func(t *testing.T) {
+		f := newFuture[int]()
+		select {
+		case <-f.Done():
+			t.Fatal("Done() closed before completion")
+		default:
+		}
+
+		f.complete(1, nil)
+		select {
+		case <-f.Done():
+		default:
+			t.Error("Done() not closed after completion")
+		}
+	})
+
+	t.Run("completed result wins over cancelled context", func(t *testing.T) {
+		f := newFuture[int]()
+		f.complete(7, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if got, err := f.Await(ctx); err != nil || got != 7 {
+			t.Errorf("Await() = %d, %v, want 7, nil", got, err)
+		}
+	})
+}
+
+func TestGo(t *testing.T) {
+	t.Run("returns value", func(t *testing.T) {
+		got, err := Go(func() (int, error) { return 42, nil }).Await(context.Background())
+		if err != nil || got != 42 {
+			t.Errorf("Await() = %d, %v, want 42, nil", got, err)
+		}
+	})
+
+	t.Run("recovers panic", func(t *testing.T) {
+		_, err := Go(func() (int, error) { panic("boom") }).Await(context.Background())
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("Await() error = %v, want *PanicError", err)
+		}
+	})
+}
+
+func TestGoOn(t *testing.T) {
+	t.Run("runs on the pool", func(t *testing.T) {
+		pool := NewPool(1)
+		defer pool.Close()
+
+		ctx := context.Background()
+		f, err := GoOn(ctx, pool, func() (string, error) { return "pooled", nil })
+		if err != nil {
+			t.Fatalf("GoOn() error = %v", err)
+		}
+		if got, err := f.Await(ctx); err != nil || got != "pooled" {
+			t.Errorf("Await() = %q, %v, want \"pooled\", nil", got, err)
+		}
+		if stats := pool.Stats(); stats.Submitted != 1 {
+			t.Errorf("pool ran %d tasks, want 1", stats.Submitted)
+		}
+	})
+
+	t.Run("fails on closed pool", func(t *testing.T) {
+		pool := NewPool(1)
+		pool.Close()
+
+		if _, err := GoOn(context.Background(), pool, func() (int, error) { return 1, nil }); err != ErrPoolClosed {
+			t.Errorf("GoOn() error = %v, want ErrPoolClosed", err)
+		}
+	})
+}
+
+func TestThen(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("chains values", func(t *testing.T) {
+		f := Then(Go(func() (int, error) { return 2, nil }), func(x int) (string, error) {
+			return fmt.Sprintf("%d!", x*3), nil
+		})
+		if got, err := f.Await(ctx); err != nil || got != "6!" {
+			t.Errorf("Await() = %q, %v, want \"6!\", nil", got, err)
+		}
+	})
+
+	t.Run("propagates errors", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		called := false
+		f := Then(Go(func() (int, error) { return 0, errBoom }), func(x int) (int, error) {
+			called = true
+			return x, nil
+		})
+		if _, err := f.Await(ctx); err != errBoom {
+			t.Errorf("Await() error = %v, want %v", err, errBoom)
+		}
+		if called {
+			t.Error("Then callback should not run after a failure")
+		}
+	})
+}
+
+func TestAll(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("collects values in order", func(t *testing.T) {
+		f := All(
+			Go(func() (int, error) { time.Sleep(5 * time.Millisecond); return 1, nil }),
+			Go(func() (int, error) { return 2, nil }),
+		)
+		got, err := f.Await(ctx)
+		if err != nil || len(got) != 2 || got[0] != 1 || got[1] != 2 {
+			t.Errorf("Await() = %v, %v, want [1 2], nil", got, err)
+		}
+	})
+
+	t.Run("fails fast", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		never := newFuture[int]()
+		f := All(never, Go(func() (int, error) { return 0, errBoom }))
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if _, err := f.Await(ctx); err != errBoom {
+			t.Errorf("Await() error = %v, want %v", err, errBoom)
+		}
+	})
+
+	t.Run("no futures", func(t *testing.T) {
+		got, err := All[int]().Await(ctx)
+		if err != nil || len(got) != 0 {
+			t.Errorf("Await() = %v, %v, want [], nil", got, err)
+		}
+	})
+}
+
+func TestAllSettled(t *testing.T) {
+	errBoom := errors.New("boom")
+	got, err := AllSettled(
+		Go(func() (int, error) { return 1, nil }),
+		Go(func() (int, error) { return 0, errBoom }),
+	).Await(context.Background())
+
+	if err != nil {
+		t.Fatalf("Await() error = %v, want nil", err)
+	}
+	if len(got) != 2 || got[0].Value != 1 || got[0].Err != nil || got[1].Err != errBoom {
+		t.Errorf("Await() = %+v, want [{1 <nil>} {0 boom}]", got)
+	}
+}
+
+func TestAny(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("first success wins", func(t *testing.T) {
+		got, err := Any(
+			Go(func() (string, error) { return "", errors.New("fail") }),
+			Go(func() (string, error) { time.Sleep(5 * time.Millisecond); return "ok", nil }),
+		).Await(ctx)
+		if err != nil || got != "ok" {
+			t.Errorf("Await() = %q, %v, want \"ok\", nil", got, err)
+		}
+	})
+
+	t.Run("joins errors when all fail", func(t *testing.T) {
+		errA := errors.New("a")
+		errB := errors.New("b")
+		_, err := Any(
+			Go(func() (int, error) { return 0, errA }),
+			Go(func() (int, error) { return 0, errB }),
+		).Await(ctx)
+		if !errors.Is(err, errA) || !errors.Is(err, errB) {
+			t.Errorf("Await() error = %v, want both a and b", err)
+		}
+	})
+
+	t.Run("no futures", func(t *testing.T) {
+		if _, err := Any[int]().Await(ctx); err != ErrNoFutures {
+			t.Errorf("Await() error = %v, want ErrNoFutures", err)
+		}
+	})
+}
+
+func TestRace(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("first completion wins even if it failed", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		_, err := Race(
+			Go(func() (int, error) { time.Sleep(50 * time.Millisecond); return 1, nil }),
+			Go(func() (int, error) { return 0, errBoom }),
+		).Await(ctx)
+		if err != errBoom {
+			t.Errorf("Await() error = %v, want %v", err, errBoom)
+		}
+	})
+
+	t.Run("no futures", func(t *testing.T) {
+		if _, err := Race[int]().Await(ctx); err != ErrNoFutures {
+			t.Errorf("Await() error = %v, want ErrNoFutures", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package async
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgeAttempts   = 2
+	defaultHedgeWindow     = 1000
+	defaultHedgeMinSamples = 20
+	// hedgeRecompute is the number of new samples after which the quantile
+	// delay is recomputed.
+	hedgeRecompute = 16
+)
+
+// HedgeConfig configures a Hedger. Zero fields select defaults.
+type HedgeConfig struct {
+	// Delay is how long to wait for a call before starting a backup call. When
+	// Quantile is set, it is only used until enough latencies have been observed.
+	// Zero starts every call at once.
+	Delay time.Duration
+	// Quantile, between 0 and 1, derives the delay from the latency of recent
+	// successful calls instead: with 0.95, a backup is started once a call has
+	// taken longer than 95% of them, which bounds the extra load to about 5%.
+	Quantile float64
+	// Window is the number of recent latencies the quantile is computed over.
+	// Defaults to 1000.
+	Window int
+	// MinSamples is the number of latencies needed before the quantile replaces
+	// Delay. Defaults to 20.
+	MinSamples int
+	// MaxAttempts is the maximum number of calls, including the first one.
+	// Defaults to 2.
+	MaxAttempts int
+	// Clock is the clock delays and latencies are measured on. Defaults to the
+	// system clock.
+	Clock Clock
+}
+
+// Hedger sends hedged requests: when a call has not returned after a delay,
+// it starts a backup call and takes whichever succeeds first, which cuts the
+// tail latency of reads served by several replicas at the cost of a little
+// extra load. The delay is fixed or follows a quantile of the observed
+// latency. It is safe for concurrent use; share one Hedger per operation so
+// that its latency statistics describe a single kind of call.
+//
+// Example:
+//
+//	hedger := async.NewHedger(async.HedgeConfig{
+//		Delay:    50 * time.Millisecond, // until enough latencies are known
+//		Quantile: 0.95,
+//	})
+//
+//	user, err := async.Hedge(ctx, hedger, func(ctx context.Context) (User, error) {
+//		return replicas.Next().GetUser(ctx, id)
+//	})
+type Hedger struct {
+	cfg HedgeConfig
+
+	mu        sync.Mutex
+	latencies []time.Duration // ring buffer of recent latencies
+	next      int             // position of the next latency in latencies
+	fresh     int             // latencies recorded since delay was computed
+	delay     time.Duration   // current quantile delay, zero until computed
+}
+
+// NewHedger returns a Hedger with the given configuration.
+func NewHedger(cfg HedgeConfig) *Hedger {
+	if cfg.Delay < 0 {
+		cfg.Delay = 0
+	}
+	if cfg.Quantile < 0 || cfg.Quantile > 1 {
+		cfg.Quantile = 0
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultHedgeWindow
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = defaultHedgeMinSamples
+	}
+	if cfg.MinSamples > cfg.Window {
+		cfg.MinSamples = cfg.Window
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultHedgeAttempts
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	return &Hedger{cfg: cfg}
+}
+
+// Hedge calls fn, and calls it again each time the hedger's delay passes
+// without a result, up to its MaxAttempts. When a call fails while no other
+// call is running, the next one starts right away instead of after the delay;
+// while other calls are still running, Hedge keeps waiting for them and for the
+// delay. Hedge returns the result of the first call that succeeds and
+// cancels the context of the others, or the error of the last call if they all
+// fail, or ctx.Err() if ctx is done first. A panic in fn is returned as a
+// *PanicError.
+//
+// fn must be safe to call concurrently and to abandon, which usually makes
+// hedging suitable for idempotent reads only.
+func Hedge[T any](ctx context.Context, h *Hedger, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		value   T
+		err     error
+		latency time.Duration
+	}
+	results := make(chan result, h.cfg.MaxAttempts)
+	launch := func() {
+		go func() {
+			var r result
+			start := h.cfg.Clock.Now()
+			r.err = catch(func() (err error) {
+				r.value, err = fn(callCtx)
+				return err
+			}, nil)
+			r.latency = h.cfg.Clock.Now().Sub(start)
+			results <- r
+		}()
+	}
+
+	delay := h.Delay()
+	timer := h.cfg.Clock.NewTimer(delay)
+	defer timer.Stop()
+	launch()
+	launched, pending := 1, 1
+
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				h.record(r.latency)
+				return r.value, nil
+			}
+			if pending > 0 {
+				continue
+			}
+			if launched == h.cfg.MaxAttempts || ctx.Err() != nil {
+				return zero, r.err
+			}
+			launch()
+			launched++
+			pending++
+			if !timer.Stop() {
+				select {
+				case <-timer.C():
+				default:
+				}
+			}
+			timer.Reset(delay)
+
+		case <-timer.C():
+			if launched < h.cfg.MaxAttempts {
+				launch()
+				launched++
+				pending++
+				timer.Reset(delay)
+			}
+
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Do is like Hedge for functions that only return an error.
+func (h *Hedger) Do(ctx context.Context, fn func(context.Context) error) error {
+	_, err := Hedge(ctx, h, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Delay returns how long a call currently waits before its backup is started.
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cfg.Quantile == 0 || len(h.latencies) < h.cfg.MinSamples {
+		return h.cfg.Delay
+	}
+	if h.delay == 0 || h.fresh >= hedgeRecompute {
+		h.delay = h.quantile()
+		h.fresh = 0
+	}
+	return h.delay
+}
+
+// record adds the latency of a successful call to the window.
+func (h *Hedger) record(d time.Duration) {
+	if h.cfg.Quantile == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < h.cfg.Window {
+		h.latencies = append(h.latencies, d)
+	} else {
+		h.latencies[h.next] = d
+	}
+	h.next = (h.next + 1) % h.cfg.Window
+	h.fresh++
+}
+
+// quantile returns the configured quantile of the recorded latencies, with a
+// minimum of one nanosecond so that a cached zero is told apart. h.mu must be held.
+func (h *Hedger) quantile() time.Duration {
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(h.cfg.Quantile*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if sorted[i] <= 0 {
+		return 1
+	}
+	return sorted[i]
+}
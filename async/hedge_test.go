@@ -0,0 +1,115 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedge(t *testing.T) {
+	t.Run("fast calls are not hedged", func(t *testing.T) {
+		h := NewHedger(HedgeConfig{Delay: time.Hour})
+		var calls int32
+		v, err := Hedge(context.Background(), h, func(context.Context) (int, error) {
+			atomic.AddInt32(&calls, 1)
+			return 42, nil
+		})
+		if v != 42 || err != nil {
+			t.Fatalf("Hedge() = %v, %v, want 42, nil", v, err)
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("backup call wins and the first is cancelled", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		h := NewHedger(HedgeConfig{Delay: 100 * time.Millisecond, Clock: clock})
+		var calls int32
+		cancelled := make(chan struct{})
+
+		type result struct {
+			v   string
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			v, err := Hedge(context.Background(), h, func(ctx context.Context) (string, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					<-ctx.Done()
+					close(cancelled)
+					return "", ctx.Err()
+				}
+				return "backup", nil
+			})
+			done <- result{v, err}
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(100 * time.Millisecond)
+		r := <-done
+		if r.v != "backup" || r.err != nil {
+			t.Fatalf("Hedge() = %q, %v, want backup, nil", r.v, r.err)
+		}
+		<-cancelled
+	})
+
+	t.Run("failures start the next call right away", func(t *testing.T) {
+		h := NewHedger(HedgeConfig{Delay: time.Hour, MaxAttempts: 3})
+		var calls int32
+		errs := []error{errors.New("first"), errors.New("second"), errors.New("third")}
+		_, err := Hedge(context.Background(), h, func(context.Context) (int, error) {
+			return 0, errs[atomic.AddInt32(&calls, 1)-1]
+		})
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+		if err != errs[2] {
+			t.Errorf("Hedge() error = %v, want %v", err, errs[2])
+		}
+	})
+
+	t.Run("delay follows the latency quantile", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		h := NewHedger(HedgeConfig{Delay: time.Second, Quantile: 0.5, MinSamples: 4, MaxAttempts: 1, Clock: clock})
+		for i := 1; i <= 4; i++ {
+			if got := h.Delay(); got != time.Second {
+				t.Fatalf("Delay() = %v with %d samples, want the fixed delay", got, i-1)
+			}
+			latency := time.Duration(i) * 10 * time.Millisecond
+			_ = h.Do(context.Background(), func(context.Context) error {
+				clock.Advance(latency)
+				return nil
+			})
+		}
+		if got := h.Delay(); got != 20*time.Millisecond {
+			t.Errorf("Delay() = %v, want the median of 20ms", got)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		h := NewHedger(HedgeConfig{Delay: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := h.Do(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Do() error = %v, want DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("panics are returned as errors", func(t *testing.T) {
+		h := NewHedger(HedgeConfig{Delay: time.Hour, MaxAttempts: 1})
+		err := h.Do(context.Background(), func(context.Context) error {
+			panic("boom")
+		})
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("Do() error = %v, want a *PanicError", err)
+		}
+	})
+}
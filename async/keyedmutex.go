@@ -0,0 +1,123 @@
+package async
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedMutex provides one mutual-exclusion lock per key, for serializing work on
+// the same user ID or file path while work on different keys runs in parallel.
+// Locks are created on demand and dropped once no goroutine holds or waits for
+// them, so the set of keys does not grow without bound. The zero value is ready
+// to use and a KeyedMutex must not be copied after first use.
+//
+// Example:
+//
+//	var accounts async.KeyedMutex[string]
+//
+//	func transfer(from, to string, amount int) {
+//		accounts.Lock(from)
+//		defer accounts.Unlock(from)
+//		// ...
+//	}
+type KeyedMutex[K comparable] struct {
+	mu    sync.Mutex
+	locks map[K]*keyedLock
+}
+
+// keyedLock is the lock of one key and the number of goroutines holding or
+// waiting for it.
+type keyedLock struct {
+	ch   chan struct{} // holds a value while locked
+	refs int
+}
+
+// Lock locks key, blocking until it is available.
+func (m *KeyedMutex[K]) Lock(key K) {
+	l := m.acquire(key)
+	l.ch <- struct{}{}
+}
+
+// LockCtx locks key, blocking until it is available or ctx is done, in which
+// case it returns ctx.Err() without holding the lock.
+//
+// Example:
+//
+//	if err := files.LockCtx(ctx, path); err != nil {
+//		return err
+//	}
+//	defer files.Unlock(path)
+func (m *KeyedMutex[K]) LockCtx(ctx context.Context, key K) error {
+	l := m.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return ctx.Err()
+	}
+}
+
+// TryLock locks key if it is not locked, without blocking, and reports whether it did.
+func (m *KeyedMutex[K]) TryLock(key K) bool {
+	l := m.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+		m.release(key, l)
+		return false
+	}
+}
+
+// Unlock unlocks key. It panics if key is not locked.
+func (m *KeyedMutex[K]) Unlock(key K) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	m.mu.Unlock()
+	if !ok {
+		panic("async: unlock of unlocked key")
+	}
+
+	select {
+	case <-l.ch:
+	default:
+		panic("async: unlock of unlocked key")
+	}
+	m.release(key, l)
+}
+
+// Len returns the number of keys currently locked or waited for.
+func (m *KeyedMutex[K]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
+
+// acquire returns the lock of key, creating it if needed, and takes a reference on it.
+func (m *KeyedMutex[K]) acquire(key K) *keyedLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.locks[key]
+	if !ok {
+		if m.locks == nil {
+			m.locks = make(map[K]*keyedLock)
+		}
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// release drops a reference on the lock of key, removing it once unused.
+func (m *KeyedMutex[K]) release(key K, l *keyedLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
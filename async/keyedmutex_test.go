@@ -0,0 +1,93 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	t.Run("serializes the same key", func(t *testing.T) {
+		var m KeyedMutex[string]
+		counters := map[string]int{"a": 0, "b": 0}
+		var mu sync.Mutex // guards the map; m guards each counter
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			key := "a"
+			if i%2 == 1 {
+				key = "b"
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Lock(key)
+				defer m.Unlock(key)
+
+				mu.Lock()
+				v := counters[key]
+				mu.Unlock()
+				time.Sleep(100 * time.Microsecond)
+				mu.Lock()
+				counters[key] = v + 1
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if counters["a"] != 50 || counters["b"] != 50 {
+			t.Errorf("counters = %v, want 50 each", counters)
+		}
+		if m.Len() != 0 {
+			t.Errorf("Len() after all unlocks = %d, want 0", m.Len())
+		}
+	})
+
+	t.Run("different keys do not block", func(t *testing.T) {
+		var m KeyedMutex[int]
+		m.Lock(1)
+		if !m.TryLock(2) {
+			t.Error("TryLock(2) while 1 is locked = false, want true")
+		}
+		if m.TryLock(1) {
+			t.Error("TryLock(1) while locked = true, want false")
+		}
+		m.Unlock(1)
+		m.Unlock(2)
+		if m.Len() != 0 {
+			t.Errorf("Len() = %d, want 0", m.Len())
+		}
+	})
+
+	t.Run("LockCtx", func(t *testing.T) {
+		var m KeyedMutex[string]
+		m.Lock("k")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := m.LockCtx(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("LockCtx() = %v, want context.DeadlineExceeded", err)
+		}
+
+		m.Unlock("k")
+		if err := m.LockCtx(context.Background(), "k"); err != nil {
+			t.Errorf("LockCtx() = %v, want nil", err)
+		}
+		m.Unlock("k")
+		if m.Len() != 0 {
+			t.Errorf("Len() = %d, want 0", m.Len())
+		}
+	})
+
+	t.Run("unlock of unlocked key panics", func(t *testing.T) {
+		var m KeyedMutex[string]
+		defer func() {
+			if recover() == nil {
+				t.Error("Unlock() of unlocked key did not panic")
+			}
+		}()
+		m.Unlock("k")
+	})
+}
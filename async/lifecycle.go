@@ -0,0 +1,254 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrShutdown is the cause of a Lifecycle's context once shutdown has started,
+// either because a signal was received or because Shutdown was called.
+// Received signals are reported as an error wrapping ErrShutdown.
+var ErrShutdown = errors.New("async: shutdown requested")
+
+// defaultShutdownTimeout bounds the shutdown of a Lifecycle when no timeout is configured.
+const defaultShutdownTimeout = 30 * time.Second
+
+// LifecycleConfig configures a Lifecycle. Zero fields select defaults.
+type LifecycleConfig struct {
+	// Signals are the signals that start the shutdown. Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+	// ShutdownTimeout bounds the whole shutdown; hooks that have not run by then
+	// are skipped. Defaults to 30s.
+	ShutdownTimeout time.Duration
+	// HookTimeout bounds each hook that does not set its own Timeout.
+	// Zero means hooks are only bounded by ShutdownTimeout.
+	HookTimeout time.Duration
+	// Clock is the clock the timeouts are measured on. Defaults to the system clock.
+	Clock Clock
+}
+
+// ShutdownHook is a function run by a Lifecycle during shutdown.
+type ShutdownHook struct {
+	// Name identifies the hook in errors.
+	Name string
+	// Priority orders the hooks: higher priorities run first, and hooks of equal
+	// priority run in reverse registration order.
+	Priority int
+	// Timeout bounds the hook. Zero selects the Lifecycle's HookTimeout.
+	Timeout time.Duration
+	// Run performs the shutdown step. Its context expires with the hook's deadline.
+	Run func(ctx context.Context) error
+}
+
+// Lifecycle coordinates the graceful shutdown of a process: it traps the
+// termination signals, cancels a root context that the rest of the program
+// runs under, then runs the registered shutdown hooks one at a time within
+// their deadlines and reports the errors they returned.
+//
+// Example:
+//
+//	lc := async.NewLifecycle(context.Background(), async.LifecycleConfig{
+//		ShutdownTimeout: 20 * time.Second,
+//	})
+//	ctx := lc.Context()
+//
+//	pool := async.NewPool(8)
+//	lc.DrainPool("workers", pool)
+//	lc.OnShutdown("http", server.Shutdown) // runs before the pool is drained
+//
+//	go server.ListenAndServe()
+//	if err := lc.Wait(); err != nil {
+//		log.Printf("unclean shutdown: %v", err)
+//		os.Exit(1)
+//	}
+type Lifecycle struct {
+	cfg    LifecycleConfig
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	sigs   chan os.Signal
+
+	mu    sync.Mutex
+	hooks []ShutdownHook
+
+	once sync.Once
+	err  error
+}
+
+// NewLifecycle returns a Lifecycle whose root context derives from ctx, and
+// starts trapping the configured signals. Shutdown starts when one of them is
+// received, when Shutdown is called or when ctx is done.
+func NewLifecycle(ctx context.Context, cfg LifecycleConfig) *Lifecycle {
+	if len(cfg.Signals) == 0 {
+		cfg.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+
+	l := &Lifecycle{cfg: cfg, sigs: make(chan os.Signal, 1)}
+	l.ctx, l.cancel = context.WithCancelCause(ctx)
+	signal.Notify(l.sigs, cfg.Signals...)
+
+	go func() {
+		select {
+		case sig := <-l.sigs:
+			l.cancel(fmt.Errorf("%w: received %v", ErrShutdown, sig))
+		case <-l.ctx.Done():
+		}
+		signal.Stop(l.sigs)
+	}()
+	return l
+}
+
+// Context returns the root context, which is cancelled once shutdown starts.
+// context.Cause reports why.
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// Shutdown starts the shutdown as if a signal had been received.
+func (l *Lifecycle) Shutdown() {
+	l.cancel(ErrShutdown)
+}
+
+// OnShutdown registers fn to run during shutdown with the default priority of
+// zero, so that hooks registered with OnShutdown run in reverse order: resources
+// acquired last are released first.
+//
+// Example:
+//
+//	db := openDB()
+//	lc.OnShutdown("db", func(context.Context) error { return db.Close() })
+func (l *Lifecycle) OnShutdown(name string, fn func(context.Context) error) {
+	l.AddHook(ShutdownHook{Name: name, Run: fn})
+}
+
+// AddHook registers a shutdown hook with its own priority and timeout.
+//
+// Example:
+//
+//	// Stop accepting traffic before anything else, within 10 seconds.
+//	lc.AddHook(async.ShutdownHook{
+//		Name:     "http",
+//		Priority: 100,
+//		Timeout:  10 * time.Second,
+//		Run:      server.Shutdown,
+//	})
+func (l *Lifecycle) AddHook(h ShutdownHook) {
+	l.mu.Lock()
+	l.hooks = append(l.hooks, h)
+	l.mu.Unlock()
+}
+
+// DrainPool registers a hook that closes pool and waits for its queued and
+// running tasks to finish. A task panic is reported as a *PanicError.
+func (l *Lifecycle) DrainPool(name string, pool *Pool) {
+	l.OnShutdown(name, func(ctx context.Context) error {
+		return waitCtx(ctx, func() (err error) {
+			// Close re-panics with the task's *PanicError; return it as is
+			// rather than letting waitCtx wrap it in another one.
+			defer func() {
+				if r := recover(); r != nil {
+					pe, ok := r.(*PanicError)
+					if !ok {
+						panic(r)
+					}
+					err = pe
+				}
+			}()
+			pool.Close()
+			return nil
+		})
+	})
+}
+
+// DrainGroup registers a hook that waits for the goroutines of g to finish and
+// reports the group's error. The goroutines are expected to stop when the
+// Lifecycle's context is cancelled.
+func (l *Lifecycle) DrainGroup(name string, g *ErrGroup) {
+	l.OnShutdown(name, func(ctx context.Context) error {
+		return waitCtx(ctx, g.Wait)
+	})
+}
+
+// Wait blocks until shutdown starts, then runs the shutdown hooks and returns
+// their errors joined together, each wrapped with the name of its hook. Hooks
+// that fail do not prevent the next ones from running. Wait may be called more
+// than once; the hooks only run the first time.
+func (l *Lifecycle) Wait() error {
+	<-l.ctx.Done()
+	l.once.Do(func() {
+		l.err = l.runHooks()
+	})
+	return l.err
+}
+
+// runHooks runs the hooks by decreasing priority, then in reverse registration order.
+func (l *Lifecycle) runHooks() error {
+	l.mu.Lock()
+	hooks := make([]ShutdownHook, len(l.hooks))
+	for i, h := range l.hooks {
+		hooks[len(hooks)-1-i] = h
+	}
+	l.mu.Unlock()
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Priority > hooks[j].Priority
+	})
+
+	ctx, cancel := contextWithTimeout(context.WithoutCancel(l.ctx), l.cfg.Clock, l.cfg.ShutdownTimeout, context.DeadlineExceeded)
+	defer cancel()
+
+	var errs []error
+	for _, h := range hooks {
+		h := h
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %q skipped: %w", h.Name, context.Cause(ctx)))
+			continue
+		}
+
+		timeout := h.Timeout
+		if timeout <= 0 {
+			timeout = l.cfg.HookTimeout
+		}
+		hookCtx, hookCancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			hookCtx, hookCancel = contextWithTimeout(ctx, l.cfg.Clock, timeout, context.DeadlineExceeded)
+		}
+		err := waitCtx(hookCtx, func() error { return h.Run(hookCtx) })
+		if err != nil && err == hookCtx.Err() {
+			// Report a deadline as such on clocks whose contexts are only cancelled.
+			err = context.Cause(hookCtx)
+		}
+		hookCancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %q: %w", h.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitCtx runs f on its own goroutine and returns its error, or ctx.Err() if
+// ctx is done first. A panic in f is returned as a *PanicError.
+func waitCtx(ctx context.Context, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- catch(f, nil)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,219 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Run("signal cancels the context and runs hooks", func(t *testing.T) {
+		lc := NewLifecycle(context.Background(), LifecycleConfig{Signals: []os.Signal{os.Interrupt}})
+
+		var ran bool
+		lc.OnShutdown("hook", func(context.Context) error {
+			ran = true
+			return nil
+		})
+
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Signal(os.Interrupt); err != nil {
+			t.Skipf("cannot send signals on this platform: %v", err)
+		}
+
+		if err := lc.Wait(); err != nil {
+			t.Errorf("Wait() = %v, want nil", err)
+		}
+		if !ran {
+			t.Error("shutdown hook did not run")
+		}
+		if cause := context.Cause(lc.Context()); !errors.Is(cause, ErrShutdown) {
+			t.Errorf("context cause = %v, want ErrShutdown", cause)
+		}
+	})
+
+	t.Run("hook order", func(t *testing.T) {
+		lc := NewLifecycle(context.Background(), LifecycleConfig{})
+
+		var order []string
+		record := func(name string) func(context.Context) error {
+			return func(context.Context) error {
+				order = append(order, name)
+				return nil
+			}
+		}
+		lc.OnShutdown("first", record("first"))
+		lc.OnShutdown("second", record("second"))
+		lc.AddHook(ShutdownHook{Name: "urgent", Priority: 10, Run: record("urgent")})
+		lc.AddHook(ShutdownHook{Name: "late", Priority: -1, Run: record("late")})
+		lc.OnShutdown("third", record("third"))
+
+		lc.Shutdown()
+		if err := lc.Wait(); err != nil {
+			t.Fatalf("Wait() = %v", err)
+		}
+
+		want := []string{"urgent", "third", "second", "first", "late"}
+		if strings.Join(order, ",") != strings.Join(want, ",") {
+			t.Errorf("hook order = %v, want %v", order, want)
+		}
+	})
+
+	t.Run("errors and deadlines", func(t *testing.T) {
+		lc := NewLifecycle(context.Background(), LifecycleConfig{
+			ShutdownTimeout: 100 * time.Millisecond,
+			HookTimeout:     20 * time.Millisecond,
+		})
+
+		errClose := errors.New("close failed")
+		lc.OnShutdown("skipped", func(context.Context) error { return nil })
+		lc.AddHook(ShutdownHook{Name: "hog", Timeout: time.Second, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+		lc.OnShutdown("slow", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+		lc.OnShutdown("panics", func(context.Context) error { panic("boom") })
+		lc.OnShutdown("failing", func(context.Context) error { return errClose })
+
+		lc.Shutdown()
+		start := time.Now()
+		err := lc.Wait()
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Wait() took %v, want the overall deadline to apply", elapsed)
+		}
+
+		if !errors.Is(err, errClose) {
+			t.Errorf("Wait() = %v, want it to include %v", err, errClose)
+		}
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("Wait() = %v, want it to include a *PanicError", err)
+		}
+		for _, name := range []string{`"slow"`, `"hog"`, `"skipped" skipped`} {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("Wait() = %v, want an error for hook %s", err, name)
+			}
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Wait() = %v, want context.DeadlineExceeded", err)
+		}
+
+		if again := lc.Wait(); again == nil || again.Error() != err.Error() {
+			t.Errorf("second Wait() = %v, want the same error", again)
+		}
+	})
+
+	t.Run("hook deadline on a fake clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		lc := NewLifecycle(context.Background(), LifecycleConfig{
+			ShutdownTimeout: time.Minute,
+			HookTimeout:     5 * time.Second,
+			Clock:           clock,
+		})
+		ran := false
+		lc.OnShutdown("next", func(context.Context) error {
+			ran = true
+			return nil
+		})
+		lc.OnShutdown("stuck", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		lc.Shutdown()
+		done := make(chan error, 1)
+		go func() { done <- lc.Wait() }()
+		clock.BlockUntil(2) // the shutdown and hook deadlines
+		clock.Advance(5 * time.Second)
+
+		err := <-done
+		if !strings.Contains(err.Error(), `"stuck"`) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Wait() = %v, want a deadline error for hook \"stuck\"", err)
+		}
+		if !ran {
+			t.Error("the hook after the timed out one did not run")
+		}
+	})
+
+	t.Run("parent cancellation starts shutdown", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+		lc := NewLifecycle(parent, LifecycleConfig{})
+		cancel()
+
+		done := make(chan error, 1)
+		go func() { done <- lc.Wait() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Wait() = %v, want nil", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Wait() did not return after the parent was cancelled")
+		}
+	})
+
+	t.Run("drains pools and groups", func(t *testing.T) {
+		lc := NewLifecycle(context.Background(), LifecycleConfig{})
+		ctx := lc.Context()
+
+		var mu sync.Mutex
+		var finished []string
+		pool := NewPool(2)
+		for i := 0; i < 4; i++ {
+			pool.Submit(func() {
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				finished = append(finished, "task")
+				mu.Unlock()
+			})
+		}
+		lc.DrainPool("pool", pool)
+
+		errConsumer := errors.New("consumer failed")
+		g, gctx := WithContext(ctx)
+		g.Go(func() error {
+			<-gctx.Done()
+			return errConsumer
+		})
+		lc.DrainGroup("consumers", g)
+
+		lc.Shutdown()
+		err := lc.Wait()
+		if !errors.Is(err, errConsumer) {
+			t.Errorf("Wait() = %v, want %v", err, errConsumer)
+		}
+		if len(finished) != 4 {
+			t.Errorf("%d pool tasks finished, want 4", len(finished))
+		}
+		if err := pool.Submit(func() {}); !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("Submit() after drain = %v, want ErrPoolClosed", err)
+		}
+	})
+
+	t.Run("DrainPool reports a task panic once", func(t *testing.T) {
+		lc := NewLifecycle(context.Background(), LifecycleConfig{})
+		pool := NewPool(1)
+		pool.Submit(func() { panic("boom") })
+		lc.DrainPool("pool", pool)
+
+		lc.Shutdown()
+		err := lc.Wait()
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Fatalf("Wait() = %v, want a *PanicError", err)
+		}
+		if pe.Value != "boom" {
+			t.Errorf("PanicError.Value = %v, want boom without nesting", pe.Value)
+		}
+	})
+}
@@ -0,0 +1,366 @@
+package async
+
+import (
+	"time"
+)
+
+// Option configures the behaviour of the helpers in this package.
+// Helpers ignore options that do not apply to them, so the same option
+// values can be shared between, for example, an ErrGroup and a Pool.
+//
+// Example:
+//
+//	eg, ctx := async.WithContext(ctx, async.WithAllErrors())
+type Option func(*options)
+
+// options holds the settings collected from a list of Option values.
+type options struct {
+	// limit caps the number of goroutines a helper runs at once.
+	// Zero means no limit.
+	limit int
+
+	// collectAll makes error-returning helpers report every error
+	// (joined with errors.Join) instead of only the first one.
+	collectAll bool
+
+	// queueCapacity is the number of tasks a pool can hold before
+	// submitters block. Zero selects the helper's default.
+	queueCapacity int
+
+	// overflow is what an event bus subscriber does with events that do
+	// not fit in its queue.
+	overflow    OverflowPolicy
+	hasOverflow bool
+
+	// minWorkers and maxWorkers bound an autoscaling pool; idleTimeout
+	// is how long a surplus worker may stay idle before it exits.
+	minWorkers    int
+	hasMinWorkers bool
+	maxWorkers    int
+	idleTimeout   time.Duration
+
+	// priorityAging is the queueing time a pool treats as worth one
+	// priority level. Zero selects the default.
+	priorityAging time.Duration
+
+	// hooks observe the life cycle of tasks run by pools and groups.
+	hooks Hooks
+
+	// panicHandler is called with every panic recovered from a task.
+	panicHandler func(*PanicError)
+
+	// leading and trailing select the edges a Debouncer fires on, and
+	// maxWait bounds how long a stream of calls can postpone it.
+	leading     bool
+	hasLeading  bool
+	trailing    bool
+	hasTrailing bool
+	maxWait     time.Duration
+
+	// ordered makes a pipeline stage emit results in input order.
+	ordered bool
+
+	// jitter is the maximum random delay a scheduler adds to each run,
+	// and runHook observes the outcome of every run.
+	jitter  time.Duration
+	runHook func(JobRun)
+
+	// clock is the source of time of time-based helpers. It is never nil.
+	clock Clock
+
+	// adaptive bounds the concurrency of pools and parallel helpers with a
+	// limit that adapts to the outcome of their tasks.
+	adaptive *AdaptiveLimiter
+}
+
+// applyOptions builds an options value from the given Option list.
+func applyOptions(opts []Option) options {
+	o := options{clock: realClock{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithAdaptiveLimiter makes a Pool or a Parallel helper acquire a token from
+// limiter before running each task, so that the limiter's adaptive limit bounds
+// how many run at once, within the bound set by the number of workers or
+// WithMaxConcurrency. The token is marked a success when the task returns nil,
+// ignored when it fails with context.Canceled, and dropped on any other error
+// or panic. A limiter may be shared with other callers of the same dependency.
+//
+// Example:
+//
+//	limiter := async.NewAdaptiveLimiter(async.AdaptiveLimiterConfig{
+//		Algorithm: async.NewGradient(async.GradientConfig{}),
+//	})
+//	users, err := async.ParallelMapCtx(ctx, ids, fetchUser, async.WithAdaptiveLimiter(limiter))
+func WithAdaptiveLimiter(limiter *AdaptiveLimiter) Option {
+	return func(o *options) {
+		o.adaptive = limiter
+	}
+}
+
+// WithClock sets the Clock a time-based helper reads the time from and waits
+// on, such as Debounce, Timeout, RetryCtx, the rate limiters, the windowed
+// aggregators, Pool, ErrGroup and Scheduler. Tests pass a FakeClock to control
+// time instead of sleeping. A nil clock selects the system clock.
+//
+// Components built from a config struct rather than options, namely
+// AdaptiveLimiter, Batcher, Bulkhead, CircuitBreaker, Hedger, Lifecycle and
+// Supervisor, do not take options: they read their clock from the Clock field
+// of their config, like the rest of their settings.
+//
+// Example:
+//
+//	clock := async.NewFakeClock(time.Now())
+//	limiter := async.NewRateLimiter(1, 1, async.WithClock(clock))
+//	limiter.Allow()            // true
+//	limiter.Allow()            // false
+//	clock.Advance(time.Second) // refill one token
+//	limiter.Allow()            // true
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		if c == nil {
+			c = realClock{}
+		}
+		o.clock = c
+	}
+}
+
+// WithAllErrors makes a helper collect every error returned by its tasks
+// and report them together using errors.Join, instead of only the first one.
+//
+// Example:
+//
+//	eg := async.NewErrGroup(async.WithAllErrors())
+//	eg.Go(func() error { return errA })
+//	eg.Go(func() error { return errB })
+//	err := eg.Wait() // errors.Is(err, errA) && errors.Is(err, errB)
+func WithAllErrors() Option {
+	return func(o *options) {
+		o.collectAll = true
+	}
+}
+
+// WithMaxConcurrency limits the number of goroutines a helper runs at once.
+// Values less than or equal to zero mean no limit.
+//
+// Example:
+//
+//	results, err := async.ParallelMapErr(rows, parse, async.WithMaxConcurrency(8))
+func WithMaxConcurrency(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.limit = n
+	}
+}
+
+// WithPanicHandler sets a function that is called with every panic recovered
+// from a goroutine started by a helper. Helpers that return errors still report
+// the panic as a *PanicError. Helpers without an error result hand it to the
+// handler instead of re-raising it as a *PanicError in the caller, which they do
+// when there is no handler: ParallelMap on return, Pool from Wait or Close, and
+// a Debouncer from the call that ran the function or, for a function run on its
+// own goroutine, from its next Call, Cancel or Flush. EventBus follows the same
+// policy with EventBusConfig.PanicHandler.
+//
+// Example:
+//
+//	pool := async.NewPool(4, async.WithPanicHandler(func(pe *async.PanicError) {
+//		log.Printf("task panicked: %v\n%s", pe.Value, pe.Stack)
+//	}))
+func WithPanicHandler(handler func(*PanicError)) Option {
+	return func(o *options) {
+		o.panicHandler = handler
+	}
+}
+
+// WithQueueCapacity sets how many tasks a pool can hold waiting for a worker
+// before Submit blocks and TrySubmit fails with ErrQueueFull, or how many
+// events an asynchronous EventBus subscriber can hold before its overflow
+// policy applies. Values less than or equal to zero select the default of twice
+// the number of workers for a pool, and the bus's QueueCapacity for a subscriber.
+//
+// Example:
+//
+//	pool := async.NewPool(4, async.WithQueueCapacity(1000))
+func WithQueueCapacity(n int) Option {
+	return func(o *options) {
+		o.queueCapacity = n
+	}
+}
+
+// WithOverflow sets what an asynchronous EventBus subscriber does with an
+// event published while its queue is full, overriding the bus's Overflow.
+//
+// Example:
+//
+//	// A dashboard only cares about the latest prices.
+//	async.Subscribe(bus, PriceChanged, render, async.WithQueueCapacity(16),
+//		async.WithOverflow(async.OverflowDropOldest))
+func WithOverflow(policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow = policy
+		o.hasOverflow = true
+	}
+}
+
+// WithMinWorkers sets the number of workers an autoscaling pool keeps running
+// even when idle. It defaults to the worker count passed to NewPool and can
+// only lower it; zero lets an idle pool shut down all of its workers.
+//
+// Example:
+//
+//	pool := async.NewPool(8, async.WithMinWorkers(1)) // shrinks to 1 worker when idle
+func WithMinWorkers(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.minWorkers = n
+		o.hasMinWorkers = true
+	}
+}
+
+// WithMaxWorkers sets the number of workers an autoscaling pool may grow to
+// while tasks are waiting in its queue. It defaults to the worker count passed
+// to NewPool and can only raise it.
+//
+// Example:
+//
+//	pool := async.NewPool(2, async.WithMaxWorkers(32))
+func WithMaxWorkers(n int) Option {
+	return func(o *options) {
+		o.maxWorkers = n
+	}
+}
+
+// WithIdleTimeout sets how long a pool worker above the minimum may stay idle
+// before it exits, and how long a KeyedLimiter keeps the bucket of an unused key.
+// Values less than or equal to zero select the default of 30 seconds.
+//
+// Example:
+//
+//	pool := async.NewPool(1, async.WithMaxWorkers(8), async.WithIdleTimeout(5*time.Second))
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// WithHooks registers callbacks that observe every task run by a Pool or an
+// ErrGroup as it is submitted, started and finished, for example to feed
+// metrics or tracing.
+//
+// Example:
+//
+//	pool := async.NewPool(4, async.WithHooks(async.Hooks{
+//		OnStart:  func(ev async.TaskEvent) { queueWait.Observe(ev.Wait.Seconds()) },
+//		OnFinish: func(ev async.TaskEvent) { taskTime.Observe(ev.Run.Seconds()) },
+//	}))
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = h
+	}
+}
+
+// WithPriorityAging sets how long a task must wait in a pool's queue to gain
+// one priority level, which keeps low-priority tasks from starving behind a
+// steady stream of high-priority ones. Values less than or equal to zero select
+// the default of one second.
+//
+// Example:
+//
+//	// A task waiting 500ms is ranked like a new task with one more priority level.
+//	pool := async.NewPool(4, async.WithPriorityAging(500*time.Millisecond))
+func WithPriorityAging(d time.Duration) Option {
+	return func(o *options) {
+		o.priorityAging = d
+	}
+}
+
+// WithLeading sets whether a Debouncer calls its function on the leading edge,
+// that is immediately on the first call of a burst. NewDebouncer defaults to
+// false and NewThrottler to true.
+//
+// Example:
+//
+//	save := async.NewDebouncer(store, time.Second, async.WithLeading(true))
+func WithLeading(on bool) Option {
+	return func(o *options) {
+		o.leading = on
+		o.hasLeading = true
+	}
+}
+
+// WithTrailing sets whether a Debouncer calls its function on the trailing edge,
+// that is with the latest argument once the burst has settled. It defaults to true.
+//
+// Example:
+//
+//	// Fire on the first click only, ignoring the rest of the burst.
+//	click := async.NewDebouncer(handle, 300*time.Millisecond,
+//		async.WithLeading(true), async.WithTrailing(false))
+func WithTrailing(on bool) Option {
+	return func(o *options) {
+		o.trailing = on
+		o.hasTrailing = true
+	}
+}
+
+// WithMaxWait bounds how long a Debouncer may postpone its function while calls
+// keep arriving, so that a constant stream of calls still fires at least once
+// per d. Values less than or equal to zero mean no bound.
+//
+// Example:
+//
+//	// Save at most one second after typing stops, and at least every 10 seconds.
+//	save := async.NewDebouncer(store, time.Second, async.WithMaxWait(10*time.Second))
+func WithMaxWait(d time.Duration) Option {
+	return func(o *options) {
+		o.maxWait = d
+	}
+}
+
+// WithOrdered makes MapStage send its results in the order their inputs were
+// received, at the cost of holding back results that complete early.
+//
+// Example:
+//
+//	lines, errc := async.MapStage(ctx, rows, 8, format, async.WithOrdered())
+func WithOrdered() Option {
+	return func(o *options) {
+		o.ordered = true
+	}
+}
+
+// WithJitter makes a Scheduler delay every run by a random duration of up to d,
+// so that jobs sharing a schedule across many instances do not all fire at once.
+//
+// Example:
+//
+//	scheduler := async.NewScheduler(async.WithJitter(30 * time.Second))
+func WithJitter(d time.Duration) Option {
+	return func(o *options) {
+		o.jitter = d
+	}
+}
+
+// WithRunHook sets a function that a Scheduler calls after every run of a job
+// with its outcome, for example to log failures or record durations.
+//
+// Example:
+//
+//	scheduler := async.NewScheduler(async.WithRunHook(func(run async.JobRun) {
+//		jobDuration.WithLabelValues(run.Name).Observe(run.Duration.Seconds())
+//	}))
+func WithRunHook(hook func(JobRun)) Option {
+	return func(o *options) {
+		o.runHook = hook
+	}
+}
@@ -0,0 +1,53 @@
+package async
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned (or passed to a panic handler) when a function run by
+// this package panics. It carries the recovered value and the stack trace of
+// the goroutine at the time of the panic.
+//
+// Example:
+//
+//	err := eg.Wait()
+//	var pe *async.PanicError
+//	if errors.As(err, &pe) {
+//		log.Printf("task panicked: %v\n%s", pe.Value, pe.Stack)
+//	}
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace captured when the panic was recovered.
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("async: recovered panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so that errors.Is and
+// errors.As can look through a PanicError.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// catch calls f and converts a panic into a *PanicError. If handler is not nil
+// it is called with the PanicError before catch returns it.
+func catch(f func() error, handler func(*PanicError)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe := &PanicError{Value: r, Stack: debug.Stack()}
+			if handler != nil {
+				handler(pe)
+			}
+			err = pe
+		}
+	}()
+	return f()
+}
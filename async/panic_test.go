@@ -0,0 +1,61 @@
+package async
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPanicError(t *testing.T) {
+	t.Run("captures value and stack", func(t *testing.T) {
+		err := catch(func() error {
+			panic("boom")
+		}, nil)
+
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Fatalf("catch() = %v, want *PanicError", err)
+		}
+		if pe.Value != "boom" {
+			t.Errorf("PanicError.Value = %v, want boom", pe.Value)
+		}
+		if !strings.Contains(string(pe.Stack), "TestPanicError") {
+			t.Errorf("PanicError.Stack does not mention the panicking function:\n%s", pe.Stack)
+		}
+		if !strings.Contains(pe.Error(), "boom") {
+			t.Errorf("PanicError.Error() = %q, want it to contain the panic value", pe.Error())
+		}
+	})
+
+	t.Run("unwraps error values", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		err := catch(func() error {
+			panic(errBoom)
+		}, nil)
+
+		if !errors.Is(err, errBoom) {
+			t.Errorf("errors.Is(%v, errBoom) = false, want true", err)
+		}
+	})
+
+	t.Run("calls handler", func(t *testing.T) {
+		var handled *PanicError
+		err := catch(func() error {
+			panic(42)
+		}, func(pe *PanicError) { handled = pe })
+
+		if handled == nil || handled != err {
+			t.Errorf("handler received %v, want %v", handled, err)
+		}
+	})
+
+	t.Run("passes through normal results", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		if err := catch(func() error { return errBoom }, nil); err != errBoom {
+			t.Errorf("catch() = %v, want %v", err, errBoom)
+		}
+		if err := catch(func() error { return nil }, nil); err != nil {
+			t.Errorf("catch() = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelMap applies a transformation function to each element of a slice concurrently.
+// Results are returned in the same order as the input slice.
+// By default every element gets its own goroutine; use WithMaxConcurrency to bound
+// them, or WithAdaptiveLimiter to let the bound adapt to the outcome of transform.
+// If transform panics, the panic is re-raised as a *PanicError in the calling goroutine
+// unless a handler is configured with WithPanicHandler; the handler is then called
+// instead, the other elements are still mapped, and the element that panicked is
+// left as the zero value.
+//
+// Example:
+//
+//	numbers := []int{1, 2, 3, 4, 5}
+//	squares := async.ParallelMap(numbers, func(x int) int {
+//		time.Sleep(100 * time.Millisecond) // Simulate work
+//		return x * x
+//	})
+//	fmt.Println(squares) // Output: [1 4 9 16 25]
+func ParallelMap[T, U any](input []T, transform func(T) U, opts ...Option) []U {
+	o := applyOptions(opts)
+	result := make([]U, len(input))
+	err := parallelDo(context.Background(), len(input), o, func(_ context.Context, i int) error {
+		if o.panicHandler == nil {
+			result[i] = transform(input[i])
+			return nil
+		}
+		// A panic dealt with by the handler must not stop the other elements.
+		_ = catch(func() error {
+			result[i] = transform(input[i])
+			return nil
+		}, o.panicHandler)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// ParallelMapErr is like ParallelMap, but the transformation may fail.
+// By default the first error stops the remaining elements from being processed
+// and is returned; with WithAllErrors every element is processed and all errors
+// are joined together. Results of successful elements keep their input positions.
+// A panic in transform is recovered and reported as a *PanicError.
+//
+// Example:
+//
+//	users, err := async.ParallelMapErr(ids, loadUser, async.WithMaxConcurrency(8))
+//	if err != nil {
+//		return err
+//	}
+func ParallelMapErr[T, U any](input []T, transform func(T) (U, error), opts ...Option) ([]U, error) {
+	return ParallelMapCtx(context.Background(), input, func(_ context.Context, item T) (U, error) {
+		return transform(item)
+	}, opts...)
+}
+
+// ParallelMapCtx is like ParallelMapErr, but honours ctx. The context passed to
+// transform is cancelled when ctx is cancelled or, in fail-fast mode, when any
+// transformation returns an error. Elements that have not started by then are skipped.
+//
+// Example:
+//
+//	rows, err := async.ParallelMapCtx(ctx, records, func(ctx context.Context, r Record) (Row, error) {
+//		return convert(ctx, r)
+//	}, async.WithMaxConcurrency(16))
+func ParallelMapCtx[T, U any](ctx context.Context, input []T, transform func(context.Context, T) (U, error), opts ...Option) ([]U, error) {
+	result := make([]U, len(input))
+	err := parallelDo(ctx, len(input), applyOptions(opts), func(ctx context.Context, i int) error {
+		value, err := transform(ctx, input[i])
+		if err != nil {
+			return err
+		}
+		result[i] = value
+		return nil
+	})
+	return result, err
+}
+
+// ParallelFilter evaluates predicate for each element concurrently and returns the
+// elements for which it reported true, in their original order. Errors and
+// cancellation are handled as in ParallelMapCtx; on error the returned slice is nil.
+//
+// Example:
+//
+//	active, err := async.ParallelFilter(ctx, accounts, func(ctx context.Context, a Account) (bool, error) {
+//		return isActive(ctx, a.ID)
+//	}, async.WithMaxConcurrency(4))
+func ParallelFilter[T any](ctx context.Context, input []T, predicate func(context.Context, T) (bool, error), opts ...Option) ([]T, error) {
+	keep := make([]bool, len(input))
+	err := parallelDo(ctx, len(input), applyOptions(opts), func(ctx context.Context, i int) error {
+		ok, err := predicate(ctx, input[i])
+		keep[i] = ok
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]T, 0, len(input))
+	for i, item := range input {
+		if keep[i] {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// ParallelForEach calls fn for each element concurrently.
+// Errors and cancellation are handled as in ParallelMapCtx.
+//
+// Example:
+//
+//	err := async.ParallelForEach(ctx, files, func(ctx context.Context, path string) error {
+//		return upload(ctx, path)
+//	}, async.WithMaxConcurrency(8), async.WithAllErrors())
+func ParallelForEach[T any](ctx context.Context, input []T, fn func(context.Context, T) error, opts ...Option) error {
+	return parallelDo(ctx, len(input), applyOptions(opts), func(ctx context.Context, i int) error {
+		return fn(ctx, input[i])
+	})
+}
+
+// parallelDo calls fn for every index in [0, n) using at most o.limit goroutines
+// (one per index when no limit is set). In fail-fast mode the first error cancels
+// the context passed to fn and stops the remaining indexes from being started.
+// Panics in fn are recovered and treated as *PanicError errors.
+func parallelDo(ctx context.Context, n int, o options, fn func(context.Context, int) error) error {
+	if n == 0 {
+		return nil
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	workers := n
+	if o.limit > 0 && o.limit < n {
+		workers = o.limit
+	}
+	if o.adaptive != nil && o.adaptive.cfg.MaxLimit < workers {
+		workers = o.adaptive.cfg.MaxLimit
+	}
+
+	var (
+		next      int64 = -1
+		completed int64
+		mu        sync.Mutex
+		errs      []error
+		wg        sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				var token *LimitToken
+				if o.adaptive != nil {
+					var err error
+					if token, err = o.adaptive.Acquire(ctx); err != nil {
+						return
+					}
+				}
+				err := catch(func() error { return fn(ctx, i) }, o.panicHandler)
+				if token != nil {
+					markToken(token, err)
+				}
+				atomic.AddInt64(&completed, 1)
+				if err == nil {
+					continue
+				}
+
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				if !o.collectAll {
+					cancel(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if int(completed) < n && (o.collectAll || len(errs) == 0) {
+		if err := parent.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if o.collectAll {
+		return errors.Join(errs...)
+	}
+	return errs[0]
+}
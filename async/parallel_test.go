@@ -0,0 +1,293 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMap(t *testing.T) {
+	t.Run("correct output and order", func(t *testing.T) {
+		input := []int{1, 2, 3, 4, 5}
+		expected := []int{2, 4, 6, 8, 10}
+
+		result := ParallelMap(input, func(x int) int {
+			time.Sleep(10 * time.Millisecond) // Simulate work
+			return x * 2
+		})
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("ParallelMap() = %v, want %v", result, expected)
+		}
+	})
+
+	t.Run("empty slice", func(t *testing.T) {
+		input := []int{}
+		result := ParallelMap(input, func(x int) int { return x * 2 })
+
+		if len(result) != 0 {
+			t.Errorf("ParallelMap() with empty slice should return empty slice")
+		}
+	})
+
+	t.Run("type transformation", func(t *testing.T) {
+		input := []int{1, 2, 3}
+		expected := []string{"1", "2", "3"}
+
+		result := ParallelMap(input, func(x int) string {
+			return fmt.Sprintf("%d", x)
+		})
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("ParallelMap() = %v, want %v", result, expected)
+		}
+	})
+}
+
+func TestParallelMapErr(t *testing.T) {
+	t.Run("preserves order", func(t *testing.T) {
+		result, err := ParallelMapErr([]int{3, 1, 2}, func(x int) (int, error) {
+			time.Sleep(time.Duration(x) * time.Millisecond)
+			return x * 10, nil
+		}, WithMaxConcurrency(2))
+
+		if err != nil {
+			t.Fatalf("ParallelMapErr() error = %v", err)
+		}
+		if !reflect.DeepEqual(result, []int{30, 10, 20}) {
+			t.Errorf("ParallelMapErr() = %v, want [30 10 20]", result)
+		}
+	})
+
+	t.Run("respects max concurrency", func(t *testing.T) {
+		var active, peak int64
+		input := make([]int, 20)
+
+		_, err := ParallelMapErr(input, func(x int) (int, error) {
+			n := atomic.AddInt64(&active, 1)
+			for {
+				old := atomic.LoadInt64(&peak)
+				if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt64(&active, -1)
+			return x, nil
+		}, WithMaxConcurrency(3))
+
+		if err != nil {
+			t.Fatalf("ParallelMapErr() error = %v", err)
+		}
+		if peak > 3 {
+			t.Errorf("peak concurrency = %d, want <= 3", peak)
+		}
+	})
+
+	t.Run("fails fast", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		var calls int64
+		input := make([]int, 100)
+
+		_, err := ParallelMapErr(input, func(x int) (int, error) {
+			atomic.AddInt64(&calls, 1)
+			return 0, errBoom
+		}, WithMaxConcurrency(1))
+
+		if err != errBoom {
+			t.Errorf("ParallelMapErr() error = %v, want %v", err, errBoom)
+		}
+		if calls != 1 {
+			t.Errorf("transform called %d times, want 1", calls)
+		}
+	})
+
+	t.Run("collects all errors", func(t *testing.T) {
+		result, err := ParallelMapErr([]int{1, 2, 3, 4}, func(x int) (int, error) {
+			if x%2 == 0 {
+				return 0, fmt.Errorf("even %d", x)
+			}
+			return x, nil
+		}, WithAllErrors())
+
+		if err == nil {
+			t.Fatal("ParallelMapErr() expected joined error")
+		}
+		if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+			t.Errorf("ParallelMapErr() joined %d errors, want 2", n)
+		}
+		if result[0] != 1 || result[2] != 3 {
+			t.Errorf("ParallelMapErr() = %v, want successful results kept", result)
+		}
+	})
+}
+
+func TestParallelMapCtx(t *testing.T) {
+	t.Run("stops on cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int64
+
+		_, err := ParallelMapCtx(ctx, make([]int, 50), func(ctx context.Context, x int) (int, error) {
+			if atomic.AddInt64(&calls, 1) == 2 {
+				cancel()
+			}
+			return x, nil
+		}, WithMaxConcurrency(1))
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ParallelMapCtx() error = %v, want context.Canceled", err)
+		}
+		if calls != 2 {
+			t.Errorf("transform called %d times, want 2", calls)
+		}
+	})
+
+	t.Run("cancels siblings on error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+
+		_, err := ParallelMapCtx(context.Background(), []int{0, 1}, func(ctx context.Context, x int) (int, error) {
+			if x == 0 {
+				return 0, errBoom
+			}
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(time.Second):
+				return 0, errors.New("sibling was not cancelled")
+			}
+		})
+
+		if err != errBoom {
+			t.Errorf("ParallelMapCtx() error = %v, want %v", err, errBoom)
+		}
+	})
+}
+
+func TestParallelFilter(t *testing.T) {
+	t.Run("keeps matching elements in order", func(t *testing.T) {
+		result, err := ParallelFilter(context.Background(), []int{1, 2, 3, 4, 5, 6}, func(_ context.Context, x int) (bool, error) {
+			return x%2 == 0, nil
+		}, WithMaxConcurrency(2))
+
+		if err != nil {
+			t.Fatalf("ParallelFilter() error = %v", err)
+		}
+		if !reflect.DeepEqual(result, []int{2, 4, 6}) {
+			t.Errorf("ParallelFilter() = %v, want [2 4 6]", result)
+		}
+	})
+
+	t.Run("returns error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		result, err := ParallelFilter(context.Background(), []int{1, 2}, func(_ context.Context, x int) (bool, error) {
+			return false, errBoom
+		})
+
+		if err != errBoom || result != nil {
+			t.Errorf("ParallelFilter() = %v, %v, want nil, %v", result, err, errBoom)
+		}
+	})
+}
+
+func TestParallelForEach(t *testing.T) {
+	t.Run("visits every element", func(t *testing.T) {
+		var sum int64
+		err := ParallelForEach(context.Background(), []int{1, 2, 3, 4}, func(_ context.Context, x int) error {
+			atomic.AddInt64(&sum, int64(x))
+			return nil
+		}, WithMaxConcurrency(2))
+
+		if err != nil {
+			t.Fatalf("ParallelForEach() error = %v", err)
+		}
+		if sum != 10 {
+			t.Errorf("ParallelForEach() sum = %d, want 10", sum)
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		err := ParallelForEach(context.Background(), nil, func(_ context.Context, x int) error {
+			return errors.New("should not be called")
+		})
+		if err != nil {
+			t.Errorf("ParallelForEach() with empty input = %v, want nil", err)
+		}
+	})
+}
+
+func TestParallelPanic(t *testing.T) {
+	t.Run("ParallelMapErr returns panic as error", func(t *testing.T) {
+		_, err := ParallelMapErr([]int{1, 2, 3}, func(x int) (int, error) {
+			if x == 2 {
+				panic("boom")
+			}
+			return x, nil
+		})
+
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("ParallelMapErr() error = %v, want *PanicError", err)
+		}
+	})
+
+	t.Run("ParallelMap re-panics in caller", func(t *testing.T) {
+		defer func() {
+			r := recover()
+			if _, ok := r.(*PanicError); !ok {
+				t.Errorf("recover() = %v, want *PanicError", r)
+			}
+		}()
+
+		ParallelMap([]int{1, 2, 3}, func(x int) int {
+			if x == 2 {
+				panic("boom")
+			}
+			return x
+		})
+		t.Error("ParallelMap() should have panicked")
+	})
+
+	t.Run("ParallelMap uses panic handler", func(t *testing.T) {
+		var handled int64
+		result := ParallelMap([]int{1, 2}, func(x int) int {
+			if x == 2 {
+				panic("boom")
+			}
+			return x
+		}, WithPanicHandler(func(*PanicError) { atomic.AddInt64(&handled, 1) }))
+
+		if handled != 1 {
+			t.Errorf("panic handler called %d times, want 1", handled)
+		}
+		if len(result) != 2 {
+			t.Errorf("ParallelMap() returned %d results, want 2", len(result))
+		}
+	})
+
+	t.Run("ParallelMap maps the other elements after a handled panic", func(t *testing.T) {
+		input := make([]int, 100)
+		for i := range input {
+			input[i] = i + 1
+		}
+		result := ParallelMap(input, func(x int) int {
+			if x == 50 {
+				panic("boom")
+			}
+			return x * 2
+		}, WithMaxConcurrency(4), WithPanicHandler(func(*PanicError) {}))
+
+		for i, v := range result {
+			want := (i + 1) * 2
+			if i+1 == 50 {
+				want = 0
+			}
+			if v != want {
+				t.Fatalf("ParallelMap()[%d] = %d, want %d", i, v, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,495 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The functions in this file are composable channel stages for streaming
+// pipelines. Every stage runs in its own goroutines, stops when its context is
+// done, and closes its output channels once it has stopped, so downstream stages
+// terminate in turn. A stage that stops early no longer drains its input; cancel
+// the context shared by the pipeline to release the stages upstream of it.
+
+// Generate returns a channel that yields values in order and is closed after
+// the last one, or as soon as ctx is done.
+//
+// Example:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//
+//	ids := async.Generate(ctx, 1, 2, 3, 4)
+//	users, errc := async.MapStage(ctx, ids, 4, loadUser)
+//	for u := range users {
+//		fmt.Println(u.Name)
+//	}
+//	if err := <-errc; err != nil {
+//		return err
+//	}
+func Generate[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// MapStage applies fn to every value received from in using the given number
+// of workers, and sends the results to the returned channel, which is closed
+// once in is exhausted or the stage stops.
+//
+// By default results are sent as soon as they are ready, so their order may
+// differ from the input order; use WithOrdered to keep it. The first error
+// returned by fn stops the stage. With WithAllErrors, failed values are skipped
+// instead and every error is reported. The error channel receives at most one
+// error, the joined errors or ctx.Err() if ctx was done before in was
+// exhausted or before every result was sent, and is closed after the output
+// channel.
+// A panic in fn is recovered and reported as a *PanicError.
+//
+// Example:
+//
+//	pages, errc := async.MapStage(ctx, urls, 8, func(ctx context.Context, url string) (Page, error) {
+//		return fetch(ctx, url)
+//	}, async.WithOrdered())
+func MapStage[T, U any](ctx context.Context, in <-chan T, workers int, fn func(context.Context, T) (U, error), opts ...Option) (<-chan U, <-chan error) {
+	o := applyOptions(opts)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	out := make(chan U)
+	errc := make(chan error, 1)
+	s := &mapStage[T, U]{fn: fn, o: o, out: out}
+	s.ctx, s.cancel = context.WithCancelCause(ctx)
+
+	go func() {
+		if o.ordered {
+			s.runOrdered(in, workers)
+		} else {
+			s.runUnordered(in, workers)
+		}
+		s.cancel(nil)
+
+		close(out)
+		if err := s.err(ctx); err != nil {
+			errc <- err
+		}
+		close(errc)
+	}()
+	return out, errc
+}
+
+// mapStage is the state shared by the goroutines of a MapStage.
+type mapStage[T, U any] struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	fn     func(context.Context, T) (U, error)
+	o      options
+	out    chan U
+
+	mu        sync.Mutex
+	errs      []error
+	drained   bool // in was closed and every value received from it
+	abandoned bool // a value or result was given up on because ctx was done
+}
+
+// mapResult is the outcome of fn for one value.
+type mapResult[U any] struct {
+	value U
+	ok    bool
+}
+
+// runUnordered runs workers that each receive from in and send their results
+// directly to out.
+func (s *mapStage[T, U]) runUnordered(in <-chan T, workers int) {
+	var wg sync.WaitGroup
+	var closed sync.Once
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, ok := recv(s.ctx, in)
+				if !ok {
+					if s.ctx.Err() == nil {
+						closed.Do(s.markDrained)
+					}
+					return
+				}
+				if r := s.apply(v); r.ok && !send(s.ctx, s.out, r.value) {
+					s.markAbandoned()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runOrdered dispatches values to workers and sends their results to out in
+// the order the values were received. At most workers values are in flight.
+func (s *mapStage[T, U]) runOrdered(in <-chan T, workers int) {
+	type job struct {
+		value  T
+		result chan mapResult[U]
+	}
+	jobs := make(chan job)
+	pending := make(chan chan mapResult[U], workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.result <- s.apply(j.value)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for {
+			v, ok := recv(s.ctx, in)
+			if !ok {
+				if s.ctx.Err() == nil {
+					s.markDrained()
+				}
+				return
+			}
+			result := make(chan mapResult[U], 1)
+			select {
+			case pending <- result:
+			case <-s.ctx.Done():
+				s.markAbandoned()
+				return
+			}
+			select {
+			case jobs <- job{value: v, result: result}:
+			case <-s.ctx.Done():
+				s.markAbandoned()
+				return
+			}
+		}
+	}()
+
+	for result := range pending {
+		var r mapResult[U]
+		select {
+		case r = <-result:
+		case <-s.ctx.Done():
+			s.markAbandoned()
+			continue
+		}
+		if r.ok && !send(s.ctx, s.out, r.value) {
+			s.markAbandoned()
+		}
+	}
+	wg.Wait()
+}
+
+// apply calls fn for v and records its error. The result is not ok if fn failed.
+func (s *mapStage[T, U]) apply(v T) mapResult[U] {
+	var value U
+	err := catch(func() (err error) {
+		value, err = s.fn(s.ctx, v)
+		return err
+	}, s.o.panicHandler)
+	if err == nil {
+		return mapResult[U]{value: value, ok: true}
+	}
+
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.mu.Unlock()
+	if !s.o.collectAll {
+		s.cancel(err)
+	}
+	return mapResult[U]{}
+}
+
+// markDrained records that every value of the input has been received.
+func (s *mapStage[T, U]) markDrained() {
+	s.mu.Lock()
+	s.drained = true
+	s.mu.Unlock()
+}
+
+// markAbandoned records that a value or its result was dropped because the
+// stage's context was done.
+func (s *mapStage[T, U]) markAbandoned() {
+	s.mu.Lock()
+	s.abandoned = true
+	s.mu.Unlock()
+}
+
+// err returns the error to report once the stage has stopped: the first error,
+// or every error joined together with WithAllErrors, or parent's error if the
+// stage stopped before its input was exhausted or before every result was sent.
+func (s *mapStage[T, U]) err(parent context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errs) > 0 {
+		if s.o.collectAll {
+			return errors.Join(s.errs...)
+		}
+		return s.errs[0]
+	}
+	if !s.drained || s.abandoned {
+		return parent.Err()
+	}
+	return nil
+}
+
+// FanOut distributes the values received from in over n channels. Each channel
+// is fed by its own goroutine, which receives a value from in and then waits
+// until that channel's consumer takes it before receiving the next one, so a
+// slow consumer holds back at most one value while the others keep receiving.
+// Use it to spread work over consumers that run at different speeds. All
+// channels are closed once in is exhausted or ctx is done; a value held for a
+// consumer when ctx is done is dropped.
+//
+// Example:
+//
+//	for _, shard := range async.FanOut(ctx, records, 4) {
+//		shard := shard
+//		eg.Go(func() error { return index(ctx, shard) })
+//	}
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n <= 0 {
+		n = 1
+	}
+
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// Merge returns a channel that yields the values received from all the given
+// channels, in no particular order, and is closed once all of them are closed
+// or ctx is done.
+//
+// Example:
+//
+//	all := async.Merge(ctx, fromDB, fromCache, fromAPI)
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		in := in
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Tee returns n channels that each yield every value received from in. A value
+// is only received from in once every channel has taken the previous one, so the
+// slowest consumer sets the pace. All channels are closed once in is exhausted
+// or ctx is done.
+//
+// Example:
+//
+//	copies := async.Tee(ctx, events, 2)
+//	go archive(copies[0])
+//	go alert(copies[1])
+func Tee[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n <= 0 {
+		n = 1
+	}
+
+	chans := make([]chan T, n)
+	outs := make([]<-chan T, n)
+	for i := range chans {
+		chans[i] = make(chan T)
+		outs[i] = chans[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, ch := range chans {
+				close(ch)
+			}
+		}()
+
+		// cases[0] waits for ctx; cases[i+1] sends to chans[i] and is
+		// disabled once that channel has taken the current value.
+		cases := make([]reflect.SelectCase, n+1)
+		cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+
+			value := reflect.ValueOf(&v).Elem()
+			for i, ch := range chans {
+				cases[i+1] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch), Send: value}
+			}
+			for left := n; left > 0; left-- {
+				chosen, _, _ := reflect.Select(cases)
+				if chosen == 0 {
+					return
+				}
+				cases[chosen].Chan = reflect.Value{}
+			}
+		}
+	}()
+	return outs
+}
+
+// OrDone returns a channel that yields the values received from in until in is
+// closed or ctx is done, whichever happens first. Use it to range over a channel
+// that is not itself tied to ctx.
+//
+// Example:
+//
+//	for msg := range async.OrDone(ctx, subscription) {
+//		handle(msg)
+//	}
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := recv(ctx, in)
+			if !ok || !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Batch groups the values received from in into slices of up to size values.
+// A batch is sent once it is full, or once maxWait has passed since its first
+// value was received, so slow inputs are not held back; a maxWait of zero or
+// less disables the time limit. The last, possibly smaller, batch is sent when
+// in is closed. The channel is closed after that, or as soon as ctx is done, in
+// which case the batch being collected is dropped. maxWait is measured on the
+// clock set with WithClock.
+//
+// Example:
+//
+//	for rows := range async.Batch(ctx, records, 500, time.Second) {
+//		if err := db.BulkInsert(ctx, rows); err != nil {
+//			return err
+//		}
+//	}
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration, opts ...Option) <-chan []T {
+	if size <= 0 {
+		size = 1
+	}
+	clock := applyOptions(opts).clock
+
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+
+		var batch []T
+		var timer Timer
+		var expired <-chan time.Time
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			b := batch
+			batch = nil
+			return send(ctx, out, b)
+		}
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				if len(batch) == 0 && maxWait > 0 {
+					timer = clock.NewTimer(maxWait)
+					expired = timer.C()
+				}
+				batch = append(batch, v)
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-expired:
+				timer, expired = nil, nil
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// recv receives a value from in. It returns false if in is closed or ctx is done.
+func recv[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case v, ok := <-in:
+		return v, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// send sends v on out. It returns false if ctx is done first.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,281 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collect receives every value from in until it is closed.
+func collect[T any](t *testing.T, in <-chan T) []T {
+	t.Helper()
+	var out []T
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return out
+			}
+			out = append(out, v)
+		case <-timeout:
+			t.Fatal("channel was not closed")
+			return nil
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	got := collect(t, Generate(context.Background(), 1, 2, 3))
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Errorf("Generate() = %v, want [1 2 3]", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := Generate(ctx, 1, 2, 3)
+	<-ch
+	cancel()
+	collect(t, ch)
+}
+
+func TestMapStage(t *testing.T) {
+	double := func(ctx context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(10-v) * time.Millisecond)
+		return v * 2, nil
+	}
+	input := []int{1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	t.Run("unordered", func(t *testing.T) {
+		out, errc := MapStage(context.Background(), Generate(context.Background(), input...), 4, double)
+		got := collect(t, out)
+		sort.Ints(got)
+		if len(got) != len(input) || got[0] != 2 || got[8] != 18 {
+			t.Errorf("MapStage() = %v, want doubled input", got)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		out, errc := MapStage(context.Background(), Generate(context.Background(), input...), 4, double, WithOrdered())
+		got := collect(t, out)
+		for i, v := range got {
+			if v != input[i]*2 {
+				t.Fatalf("MapStage() = %v, want doubled input in order", got)
+			}
+		}
+		if len(got) != len(input) {
+			t.Errorf("MapStage() returned %d values, want %d", len(got), len(input))
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+	})
+
+	errOdd := errors.New("odd")
+	failOdd := func(ctx context.Context, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v, nil
+	}
+
+	for _, ordered := range []bool{false, true} {
+		ordered := ordered
+		opts := []Option{}
+		name := "unordered"
+		if ordered {
+			opts = append(opts, WithOrdered())
+			name = "ordered"
+		}
+
+		t.Run("stops on error "+name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			out, errc := MapStage(ctx, Generate(ctx, input...), 2, failOdd, opts...)
+			collect(t, out)
+			if err := <-errc; !errors.Is(err, errOdd) {
+				t.Errorf("error = %v, want %v", err, errOdd)
+			}
+		})
+
+		t.Run("collects all errors "+name, func(t *testing.T) {
+			out, errc := MapStage(context.Background(), Generate(context.Background(), input...), 2, failOdd,
+				append(opts, WithAllErrors())...)
+			got := collect(t, out)
+			if len(got) != 4 {
+				t.Errorf("MapStage() = %v, want the 4 even values", got)
+			}
+			err := <-errc
+			if !errors.Is(err, errOdd) {
+				t.Errorf("error = %v, want %v", err, errOdd)
+			}
+			if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 5 {
+				t.Errorf("error = %v, want 5 joined errors", err)
+			}
+		})
+
+		t.Run("cancellation "+name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan int)
+			out, errc := MapStage(ctx, in, 2, double, opts...)
+			in <- 1
+			<-out
+			cancel()
+			collect(t, out)
+			if err := <-errc; !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+		})
+
+		t.Run("cancellation after the input is exhausted "+name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan int, 1)
+			in <- 1
+			close(in)
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			out, errc := MapStage(ctx, in, 2, func(_ context.Context, v int) (int, error) {
+				close(started)
+				<-release
+				return v, nil
+			}, opts...)
+			<-started
+			time.Sleep(10 * time.Millisecond) // let the stage see in closed
+			cancel()
+			close(release)
+
+			got := collect(t, out)
+			if err := <-errc; len(got) < 1 && !errors.Is(err, context.Canceled) {
+				t.Errorf("received no result with error = %v, want context.Canceled", err)
+			}
+		})
+	}
+
+	t.Run("panics are reported", func(t *testing.T) {
+		out, errc := MapStage(context.Background(), Generate(context.Background(), 1), 1,
+			func(context.Context, int) (int, error) { panic("boom") })
+		collect(t, out)
+		var pe *PanicError
+		if err := <-errc; !errors.As(err, &pe) {
+			t.Errorf("error = %v, want *PanicError", err)
+		}
+	})
+}
+
+func TestFanOutMerge(t *testing.T) {
+	ctx := context.Background()
+	input := make([]int, 100)
+	for i := range input {
+		input[i] = i
+	}
+
+	outs := FanOut(ctx, Generate(ctx, input...), 4)
+	if len(outs) != 4 {
+		t.Fatalf("FanOut() returned %d channels, want 4", len(outs))
+	}
+
+	got := collect(t, Merge(ctx, outs...))
+	sort.Ints(got)
+	if len(got) != len(input) {
+		t.Fatalf("Merge(FanOut()) returned %d values, want %d", len(got), len(input))
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("Merge(FanOut()) = %v, want every input exactly once", got)
+		}
+	}
+}
+
+func TestMergeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	never := make(chan int)
+	out := Merge(ctx, never, Generate(ctx, 1))
+	<-out
+	cancel()
+	collect(t, out)
+}
+
+func TestTee(t *testing.T) {
+	ctx := context.Background()
+	outs := Tee(ctx, Generate(ctx, 1, 2, 3), 3)
+
+	results := make([][]int, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		i, out := i, out
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out {
+				results[i] = append(results[i], v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+			t.Errorf("Tee() output %d = %v, want [1 2 3]", i, got)
+		}
+	}
+}
+
+func TestOrDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int, 1)
+	in <- 1
+	out := OrDone(ctx, in)
+
+	if v := <-out; v != 1 {
+		t.Errorf("OrDone() = %d, want 1", v)
+	}
+	cancel()
+	collect(t, out)
+}
+
+func TestBatch(t *testing.T) {
+	t.Run("flushes on size and close", func(t *testing.T) {
+		ctx := context.Background()
+		got := collect(t, Batch(ctx, Generate(ctx, 1, 2, 3, 4, 5), 2, 0))
+		if len(got) != 3 || len(got[0]) != 2 || len(got[2]) != 1 || got[2][0] != 5 {
+			t.Errorf("Batch() = %v, want [[1 2] [3 4] [5]]", got)
+		}
+	})
+
+	t.Run("flushes on max wait", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		in := make(chan int)
+		out := Batch(ctx, in, 10, 20*time.Millisecond)
+
+		in <- 1
+		in <- 2
+		select {
+		case b := <-out:
+			if len(b) != 2 {
+				t.Errorf("Batch() = %v, want [1 2]", b)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("batch was not flushed after max wait")
+		}
+		close(in)
+		collect(t, out)
+	})
+
+	t.Run("stops on cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		out := Batch(ctx, in, 10, time.Hour)
+		in <- 1
+		cancel()
+		if got := collect(t, out); len(got) != 0 {
+			t.Errorf("Batch() after cancel = %v, want nothing", got)
+		}
+	})
+}
@@ -0,0 +1,680 @@
+package async
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned when a task is submitted to a pool that has been closed.
+	ErrPoolClosed = errors.New("async: pool is closed")
+	// ErrQueueFull is returned by TrySubmit when the pool's queue has no free
+	// capacity, and when a value overflows a queue under OverflowError.
+	ErrQueueFull = errors.New("async: queue is full")
+)
+
+const (
+	// defaultIdleTimeout is how long a surplus pool worker may stay idle by default.
+	defaultIdleTimeout = 30 * time.Second
+	// defaultPriorityAging is the queueing time a pool treats as worth one priority level.
+	defaultPriorityAging = time.Second
+)
+
+// Pool represents a worker pool that can execute tasks concurrently.
+// It maintains a fixed number of workers and distributes tasks among them.
+// Tasks wait in a bounded queue (twice the maximum number of workers unless set
+// with WithQueueCapacity); submitting to a full queue blocks, times out, or fails
+// depending on whether Submit, SubmitCtx or TrySubmit is used.
+//
+// With WithMaxWorkers and WithMinWorkers the pool autoscales: it starts extra
+// workers while tasks are waiting in the queue and retires workers that have
+// been idle for the idle timeout, staying within the configured bounds.
+//
+// A panicking task is recovered; the *PanicError is passed to the handler set with
+// WithPanicHandler or, if there is none, re-raised by the next call to Wait or Close.
+//
+// Tasks normally run in submission order. SubmitPriority lets urgent tasks jump
+// ahead, and SubmitKeyed runs tasks that share a key one at a time.
+//
+// Stats reports the pool's current load and task latencies, and WithHooks
+// observes every task as it is submitted, started and finished.
+//
+// Example:
+//
+//	pool := async.NewPool(3) // 3 workers
+//	defer pool.Close()
+//
+//	tasks := []func(){
+//		func() { fmt.Println("Task 1") },
+//		func() { fmt.Println("Task 2") },
+//		func() { fmt.Println("Task 3") },
+//	}
+//	pool.Submit(tasks...)
+//	pool.Wait()
+type Pool struct {
+	mu      sync.Mutex
+	queue   taskHeap
+	keys    map[string][]poolTask // tasks waiting for an earlier task with the same key
+	parked  int                   // total number of tasks waiting in keys
+	closed  bool
+	running int
+
+	clock Clock
+	epoch time.Time     // reference point for task ranks
+	aging time.Duration // queueing time worth one priority level
+
+	adaptive *AdaptiveLimiter // bounds the running tasks below the workers, if set
+
+	minWorkers  int
+	maxWorkers  int
+	live        int           // running workers
+	idle        int           // workers waiting for a task
+	idleTimeout time.Duration // how long a worker above minWorkers may stay idle
+	resized     chan struct{} // closed and replaced to wake idle workers after Resize
+
+	slots chan struct{} // one token per occupied queue slot
+	avail chan struct{} // one token per task waiting to be picked up
+	done  chan struct{} // closed by Close to release blocked submitters
+	quit  chan struct{} // closed once the workers should exit
+
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+
+	panicHandler func(*PanicError)
+	panicked     error
+
+	hooks     Hooks
+	seq       uint64
+	submitted uint64
+	completed uint64
+	failed    uint64
+	rejected  uint64
+	waitTime  histogram
+	runTime   histogram
+}
+
+// poolTask is a task waiting in a Pool's queue.
+type poolTask struct {
+	fn        func()
+	id        uint64
+	submitted time.Time
+
+	priority int
+	rank     time.Duration // queue order: lower ranks run first
+	key      string
+	keyed    bool
+}
+
+// taskHeap orders queued tasks by rank, then by submission order.
+// It implements heap.Interface.
+type taskHeap []poolTask
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank < h[j].rank
+	}
+	return h[i].id < h[j].id
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x any) { *h = append(*h, x.(poolTask)) }
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = poolTask{}
+	*h = old[:n-1]
+	return task
+}
+
+// PoolStats is a snapshot of a Pool's state and activity, as returned by Pool.Stats.
+type PoolStats struct {
+	// Queued is the number of tasks waiting for a worker.
+	Queued int
+	// Running is the number of tasks currently executing.
+	Running int
+	// Workers is the number of running workers, busy or idle.
+	Workers int
+	// Submitted is the number of tasks accepted since the pool was created.
+	Submitted uint64
+	// Completed is the number of tasks that returned normally.
+	Completed uint64
+	// Failed is the number of tasks that panicked.
+	Failed uint64
+	// Rejected is the number of submissions refused because the pool was
+	// closed, the queue was full or the submit context expired.
+	Rejected uint64
+	// WaitTime is the distribution of the time tasks spent queued.
+	WaitTime Histogram
+	// RunTime is the distribution of the time tasks spent executing.
+	RunTime Histogram
+}
+
+// NewPool creates a new worker pool with the specified number of workers.
+// Unless bounds are set with WithMinWorkers and WithMaxWorkers, the pool keeps
+// exactly that many workers. The idle timeout, priority aging and task
+// statistics are measured on the clock set with WithClock. With
+// WithAdaptiveLimiter, workers also wait for a token from the limiter before
+// running each task.
+//
+// Example:
+//
+//	// Start with 2 workers, grow to 16 under load, shrink back to 2 after 30s idle.
+//	pool := async.NewPool(2, async.WithMaxWorkers(16), async.WithIdleTimeout(30*time.Second))
+func NewPool(workers int, opts ...Option) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	o := applyOptions(opts)
+	minWorkers, maxWorkers := workers, workers
+	if o.hasMinWorkers && o.minWorkers < minWorkers {
+		minWorkers = o.minWorkers
+	}
+	if o.maxWorkers > maxWorkers {
+		maxWorkers = o.maxWorkers
+	}
+	idleTimeout := o.idleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+	capacity := o.queueCapacity
+	if capacity <= 0 {
+		capacity = maxWorkers * 2
+	}
+	aging := o.priorityAging
+	if aging <= 0 {
+		aging = defaultPriorityAging
+	}
+
+	p := &Pool{
+		minWorkers:   minWorkers,
+		maxWorkers:   maxWorkers,
+		idleTimeout:  idleTimeout,
+		keys:         make(map[string][]poolTask),
+		clock:        o.clock,
+		adaptive:     o.adaptive,
+		epoch:        o.clock.Now(),
+		aging:        aging,
+		resized:      make(chan struct{}),
+		slots:        make(chan struct{}, capacity),
+		avail:        make(chan struct{}, capacity),
+		done:         make(chan struct{}),
+		quit:         make(chan struct{}),
+		panicHandler: o.panicHandler,
+		hooks:        o.hooks,
+	}
+
+	// Start workers
+	p.mu.Lock()
+	for i := 0; i < workers; i++ {
+		p.spawn()
+	}
+	p.mu.Unlock()
+
+	return p
+}
+
+// Submit adds tasks to the pool for execution, blocking while the queue is full.
+// Nil tasks are ignored. It returns ErrPoolClosed, without submitting the
+// remaining tasks, if the pool is closed.
+func (p *Pool) Submit(tasks ...func()) error {
+	for _, task := range tasks {
+		if task == nil {
+			continue
+		}
+		if err := p.SubmitCtx(context.Background(), task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubmitCtx adds a task to the pool, blocking while the queue is full until ctx
+// is done. It returns ctx.Err() if the task could not be queued in time and
+// ErrPoolClosed if the pool is closed.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+//	defer cancel()
+//	if err := pool.SubmitCtx(ctx, task); err != nil {
+//		return err // queue stayed full for 50ms
+//	}
+func (p *Pool) SubmitCtx(ctx context.Context, task func()) error {
+	return p.submit(ctx, poolTask{fn: task}, true)
+}
+
+// TrySubmit adds a task to the pool without blocking.
+// It returns ErrQueueFull if the queue has no free capacity and
+// ErrPoolClosed if the pool is closed.
+func (p *Pool) TrySubmit(task func()) error {
+	return p.submit(context.Background(), poolTask{fn: task}, false)
+}
+
+// SubmitPriority is like SubmitCtx, but queued tasks with a higher priority run
+// before tasks with a lower one; tasks submitted with SubmitCtx have priority 0.
+// To keep low-priority work from starving, every second spent in the queue
+// (or the duration set with WithPriorityAging) counts as one extra priority level.
+//
+// Example:
+//
+//	pool.SubmitPriority(ctx, 10, handleUserRequest) // runs ahead of batch work
+//	pool.SubmitPriority(ctx, 0, reindexBatch)
+func (p *Pool) SubmitPriority(ctx context.Context, priority int, task func()) error {
+	return p.submit(ctx, poolTask{fn: task, priority: priority}, true)
+}
+
+// SubmitKeyed is like SubmitCtx, but tasks submitted with the same key never
+// run concurrently: each starts only after the previous task with that key has
+// finished, in submission order. Tasks with different keys run in parallel.
+// A task waiting for its key occupies a queue slot.
+//
+// Example:
+//
+//	for _, ev := range events {
+//		ev := ev
+//		pool.SubmitKeyed(ctx, ev.UserID, func() { apply(ev) }) // per-user order is kept
+//	}
+func (p *Pool) SubmitKeyed(ctx context.Context, key string, task func()) error {
+	return p.submit(ctx, poolTask{fn: task, key: key, keyed: true}, true)
+}
+
+// Resize sets the number of workers to exactly n (at least 1), replacing any
+// autoscaling bounds. New workers start immediately; surplus workers exit as
+// soon as they finish their current task.
+//
+// Example:
+//
+//	pool.Resize(32) // peak hours
+//	pool.Resize(4)  // back to normal
+func (p *Pool) Resize(n int) {
+	if n <= 0 {
+		n = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.minWorkers, p.maxWorkers = n, n
+	if p.closed {
+		return
+	}
+	for p.live < n {
+		p.spawn()
+	}
+	close(p.resized)
+	p.resized = make(chan struct{})
+}
+
+// Workers returns the number of workers currently running.
+func (p *Pool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.live
+}
+
+// Stats returns a snapshot of the pool's current state and activity.
+//
+// Example:
+//
+//	s := pool.Stats()
+//	fmt.Printf("queued=%d running=%d p99=%v\n", s.Queued, s.Running, s.RunTime.Quantile(0.99))
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	stats := PoolStats{
+		Queued:  len(p.queue) + p.parked,
+		Running: p.running,
+		Workers: p.live,
+	}
+	p.mu.Unlock()
+
+	stats.Submitted = atomic.LoadUint64(&p.submitted)
+	stats.Completed = atomic.LoadUint64(&p.completed)
+	stats.Failed = atomic.LoadUint64(&p.failed)
+	stats.Rejected = atomic.LoadUint64(&p.rejected)
+	stats.WaitTime = p.waitTime.snapshot()
+	stats.RunTime = p.runTime.snapshot()
+	return stats
+}
+
+// Wait blocks until all submitted tasks have completed.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+	p.repanic()
+}
+
+// Close shuts down the pool and waits for all queued tasks to complete.
+// Submitters blocked on a full queue are released with ErrPoolClosed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.quitOnce.Do(func() { close(p.quit) })
+	p.repanic()
+}
+
+// submit acquires a queue slot for task, blocking until ctx is done if block is
+// set, and enqueues it.
+func (p *Pool) submit(ctx context.Context, task poolTask, block bool) error {
+	if task.fn == nil {
+		return nil
+	}
+
+	select {
+	case <-p.done:
+		return p.reject(ErrPoolClosed)
+	default:
+	}
+
+	if block {
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.done:
+			return p.reject(ErrPoolClosed)
+		case <-ctx.Done():
+			return p.reject(ctx.Err())
+		}
+	} else {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return p.reject(ErrQueueFull)
+		}
+	}
+	return p.enqueue(task)
+}
+
+// enqueue adds a task to the queue once its slot has been acquired. A keyed task
+// whose key is busy is parked until the running task with that key finishes.
+func (p *Pool) enqueue(task poolTask) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return p.reject(ErrPoolClosed)
+	}
+
+	task.id = atomic.AddUint64(&p.seq, 1)
+	task.submitted = p.clock.Now()
+	task.rank = task.submitted.Sub(p.epoch) - time.Duration(task.priority)*p.aging
+	p.wg.Add(1)
+
+	if waiting, busy := p.keys[task.key]; task.keyed && busy {
+		p.keys[task.key] = append(waiting, task)
+		p.parked++
+	} else {
+		if task.keyed {
+			p.keys[task.key] = nil
+		}
+		p.push(task)
+	}
+	p.mu.Unlock()
+
+	atomic.AddUint64(&p.submitted, 1)
+	p.hooks.submit(TaskEvent{ID: task.id})
+	return nil
+}
+
+// push makes a task available to the workers, starting a new worker if every
+// running one is busy and the pool may still grow. p.mu must be held.
+func (p *Pool) push(task poolTask) {
+	heap.Push(&p.queue, task)
+	p.avail <- struct{}{}
+	if len(p.queue) > p.idle && p.live < p.maxWorkers {
+		p.spawn()
+	}
+}
+
+// releaseKey is called when a keyed task finishes. It queues the next task
+// waiting for the same key, or frees the key. p.mu must be held.
+func (p *Pool) releaseKey(key string) {
+	waiting := p.keys[key]
+	if len(waiting) == 0 {
+		delete(p.keys, key)
+		return
+	}
+
+	next := waiting[0]
+	waiting[0] = poolTask{}
+	p.keys[key] = waiting[1:]
+	p.parked--
+	p.push(next)
+}
+
+// reject counts a refused submission and returns err.
+func (p *Pool) reject(err error) error {
+	atomic.AddUint64(&p.rejected, 1)
+	return err
+}
+
+// spawn starts a new worker. p.mu must be held.
+func (p *Pool) spawn() {
+	p.live++
+	go p.worker()
+}
+
+// dequeue removes the next task from the queue, marks it as running and frees its slot.
+func (p *Pool) dequeue() poolTask {
+	p.mu.Lock()
+	task := heap.Pop(&p.queue).(poolTask)
+	p.running++
+	p.mu.Unlock()
+
+	<-p.slots
+	return task
+}
+
+// worker is the internal worker function that processes tasks.
+// It exits when the pool shuts down, when the pool has been resized below the
+// number of running workers, or after idling for the idle timeout while more
+// than the minimum number of workers are running.
+func (p *Pool) worker() {
+	idle := p.clock.NewTimer(p.idleTimeout)
+	defer idle.Stop()
+
+	for {
+		p.mu.Lock()
+		if p.live > p.maxWorkers {
+			p.live--
+			p.mu.Unlock()
+			return
+		}
+		p.idle++
+		resized := p.resized
+		p.mu.Unlock()
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C():
+			default:
+			}
+		}
+		idle.Reset(p.idleTimeout)
+
+		select {
+		case <-p.avail:
+			p.setIdle(-1)
+			p.run(p.dequeue())
+		case <-resized:
+			p.setIdle(-1)
+		case <-idle.C():
+			if p.retireIdle() {
+				return
+			}
+		case <-p.quit:
+			p.mu.Lock()
+			p.idle--
+			p.live--
+			p.mu.Unlock()
+			return
+		}
+	}
+}
+
+// setIdle adjusts the number of idle workers by delta.
+func (p *Pool) setIdle(delta int) {
+	p.mu.Lock()
+	p.idle += delta
+	p.mu.Unlock()
+}
+
+// retireIdle is called by a worker whose idle timer fired. It reports whether
+// the worker should exit, which is the case when the pool runs more than its
+// minimum number of workers and no task is waiting.
+func (p *Pool) retireIdle() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.idle--
+	if p.live > p.minWorkers && len(p.queue) == 0 {
+		p.live--
+		return true
+	}
+	return false
+}
+
+// run executes a single task, recording its statistics and a panic if no
+// handler is configured.
+func (p *Pool) run(task poolTask) {
+	defer p.wg.Done()
+
+	var token *LimitToken
+	if p.adaptive != nil {
+		token, _ = p.adaptive.Acquire(context.Background())
+	}
+
+	started := p.clock.Now()
+	wait := started.Sub(task.submitted)
+	p.waitTime.observe(wait)
+	p.hooks.start(TaskEvent{ID: task.id, Wait: wait})
+
+	err := catch(func() error {
+		task.fn()
+		return nil
+	}, p.panicHandler)
+	if token != nil {
+		markToken(token, err)
+	}
+
+	elapsed := p.clock.Now().Sub(started)
+	p.runTime.observe(elapsed)
+	if err != nil {
+		atomic.AddUint64(&p.failed, 1)
+	} else {
+		atomic.AddUint64(&p.completed, 1)
+	}
+
+	p.mu.Lock()
+	p.running--
+	if task.keyed {
+		p.releaseKey(task.key)
+	}
+	if err != nil && p.panicHandler == nil && p.panicked == nil {
+		p.panicked = err
+	}
+	p.mu.Unlock()
+
+	p.hooks.finish(TaskEvent{ID: task.id, Wait: wait, Run: elapsed, Err: err})
+}
+
+// repanic re-raises the first unhandled task panic, if any, in the caller's goroutine.
+func (p *Pool) repanic() {
+	p.mu.Lock()
+	err := p.panicked
+	p.panicked = nil
+	p.mu.Unlock()
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TypedPool is a worker pool that applies the same function to every submitted
+// input and returns a Future for each task's result. It is built on Pool and
+// shares its queueing, backpressure and panic-recovery behaviour.
+//
+// Example:
+//
+//	pool := async.NewTypedPool(4, func(ctx context.Context, id int) (User, error) {
+//		return loadUser(ctx, id)
+//	})
+//	defer pool.Close()
+//
+//	future, err := pool.Submit(ctx, 42)
+//	if err != nil {
+//		return err // pool closed or ctx expired while the queue was full
+//	}
+//	user, err := future.Await(ctx)
+type TypedPool[T, R any] struct {
+	pool         *Pool
+	fn           func(context.Context, T) (R, error)
+	panicHandler func(*PanicError)
+}
+
+// NewTypedPool creates a TypedPool with the specified number of workers that runs fn for each input.
+func NewTypedPool[T, R any](workers int, fn func(context.Context, T) (R, error), opts ...Option) *TypedPool[T, R] {
+	return &TypedPool[T, R]{
+		pool:         NewPool(workers, opts...),
+		fn:           fn,
+		panicHandler: applyOptions(opts).panicHandler,
+	}
+}
+
+// Submit queues input for processing, blocking while the queue is full until ctx is done.
+// The same ctx is passed to the pool's function; if it is cancelled before the
+// task starts, the future completes with ctx.Err() without running the function.
+// A panic in the function completes the future with a *PanicError.
+func (p *TypedPool[T, R]) Submit(ctx context.Context, input T) (*Future[R], error) {
+	f, task := p.task(ctx, input)
+	if err := p.pool.SubmitCtx(ctx, task); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// TrySubmit is like Submit but returns ErrQueueFull instead of blocking when the queue is full.
+func (p *TypedPool[T, R]) TrySubmit(ctx context.Context, input T) (*Future[R], error) {
+	f, task := p.task(ctx, input)
+	if err := p.pool.TrySubmit(task); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Wait blocks until all submitted tasks have completed.
+func (p *TypedPool[T, R]) Wait() {
+	p.pool.Wait()
+}
+
+// Close shuts down the pool and waits for all queued tasks to complete.
+func (p *TypedPool[T, R]) Close() {
+	p.pool.Close()
+}
+
+// task builds the pool task that runs fn for input and completes the returned future.
+func (p *TypedPool[T, R]) task(ctx context.Context, input T) (*Future[R], func()) {
+	f := newFuture[R]()
+	return f, func() {
+		if err := ctx.Err(); err != nil {
+			var zero R
+			f.complete(zero, err)
+			return
+		}
+
+		f.run(func() (R, error) { return p.fn(ctx, input) }, p.panicHandler)
+	}
+}
@@ -0,0 +1,636 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	t.Run("executes tasks concurrently", func(t *testing.T) {
+		pool := NewPool(3)
+		defer pool.Close()
+
+		var counter int64
+
+		// Submit 10 tasks
+		tasks := make([]func(), 10)
+		for i := 0; i < 10; i++ {
+			tasks[i] = func() {
+				atomic.AddInt64(&counter, 1)
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		pool.Submit(tasks...)
+		pool.Wait()
+
+		if counter != 10 {
+			t.Errorf("Pool executed %d tasks, want 10", counter)
+		}
+	})
+
+	t.Run("handles empty task list", func(t *testing.T) {
+		pool := NewPool(2)
+		defer pool.Close()
+
+		pool.Submit() // Submit nothing
+		pool.Wait()   // Should not hang
+	})
+
+	t.Run("handles nil tasks", func(t *testing.T) {
+		pool := NewPool(2)
+		defer pool.Close()
+
+		pool.Submit(nil, func() {}, nil)
+		pool.Wait()
+	})
+
+	t.Run("invalid worker count", func(t *testing.T) {
+		pool := NewPool(0)
+		defer pool.Close()
+
+		var executed bool
+		pool.Submit(func() { executed = true })
+		pool.Wait()
+
+		if !executed {
+			t.Error("Pool with 0 workers should default to 1 worker")
+		}
+	})
+}
+
+func TestPoolPanic(t *testing.T) {
+	t.Run("does not hang and re-panics in Wait", func(t *testing.T) {
+		pool := NewPool(1)
+		defer pool.Close()
+
+		var executed int64
+		pool.Submit(func() { panic("boom") }, func() { atomic.AddInt64(&executed, 1) })
+
+		func() {
+			defer func() {
+				r := recover()
+				if _, ok := r.(*PanicError); !ok {
+					t.Errorf("recover() = %v, want *PanicError", r)
+				}
+			}()
+			pool.Wait()
+		}()
+
+		if atomic.LoadInt64(&executed) != 1 {
+			t.Error("task after a panicking task was not executed")
+		}
+	})
+
+	t.Run("uses panic handler", func(t *testing.T) {
+		var handled int64
+		pool := NewPool(2, WithPanicHandler(func(*PanicError) {
+			atomic.AddInt64(&handled, 1)
+		}))
+		defer pool.Close()
+
+		pool.Submit(func() { panic("a") }, func() { panic("b") })
+		pool.Wait()
+
+		if handled != 2 {
+			t.Errorf("panic handler called %d times, want 2", handled)
+		}
+	})
+}
+
+func TestPoolBackpressure(t *testing.T) {
+	t.Run("TrySubmit reports a full queue", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(1))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Submit(func() { close(started); <-release })
+		<-started
+
+		if err := pool.TrySubmit(func() {}); err != nil {
+			t.Fatalf("TrySubmit() on a free slot = %v, want nil", err)
+		}
+		if err := pool.TrySubmit(func() {}); err != ErrQueueFull {
+			t.Errorf("TrySubmit() on a full queue = %v, want ErrQueueFull", err)
+		}
+		close(release)
+	})
+
+	t.Run("SubmitCtx times out on a full queue", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(1))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		pool.Submit(func() { <-release }, func() {})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := pool.SubmitCtx(ctx, func() {})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("SubmitCtx() = %v, want context.DeadlineExceeded", err)
+		}
+		close(release)
+	})
+
+	t.Run("Close is not blocked by a full queue", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(1))
+
+		release := make(chan struct{})
+		pool.Submit(func() { <-release }, func() {})
+
+		submitted := make(chan error, 1)
+		go func() { submitted <- pool.Submit(func() {}) }()
+
+		closed := make(chan struct{})
+		go func() {
+			pool.Close()
+			close(closed)
+		}()
+
+		select {
+		case err := <-submitted:
+			if err != ErrPoolClosed {
+				t.Errorf("blocked Submit() = %v, want ErrPoolClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("blocked Submit() was not released by Close")
+		}
+
+		close(release)
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("Close() did not return")
+		}
+	})
+
+	t.Run("rejects tasks after Close", func(t *testing.T) {
+		pool := NewPool(2)
+		pool.Close()
+
+		if err := pool.Submit(func() {}); err != ErrPoolClosed {
+			t.Errorf("Submit() after Close = %v, want ErrPoolClosed", err)
+		}
+		if err := pool.TrySubmit(func() {}); err != ErrPoolClosed {
+			t.Errorf("TrySubmit() after Close = %v, want ErrPoolClosed", err)
+		}
+		if err := pool.SubmitCtx(context.Background(), func() {}); err != ErrPoolClosed {
+			t.Errorf("SubmitCtx() after Close = %v, want ErrPoolClosed", err)
+		}
+		pool.Close() // Closing twice is allowed
+	})
+
+	t.Run("Close runs queued tasks", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(10))
+
+		var counter int64
+		for i := 0; i < 10; i++ {
+			pool.Submit(func() {
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&counter, 1)
+			})
+		}
+		pool.Close()
+
+		if counter != 10 {
+			t.Errorf("Close() ran %d tasks, want 10", counter)
+		}
+	})
+}
+
+func TestTypedPool(t *testing.T) {
+	t.Run("returns results through futures", func(t *testing.T) {
+		pool := NewTypedPool(2, func(_ context.Context, x int) (int, error) {
+			return x * x, nil
+		})
+		defer pool.Close()
+
+		ctx := context.Background()
+		futures := make([]*Future[int], 5)
+		for i := range futures {
+			f, err := pool.Submit(ctx, i)
+			if err != nil {
+				t.Fatalf("Submit() error = %v", err)
+			}
+			futures[i] = f
+		}
+
+		for i, f := range futures {
+			got, err := f.Await(ctx)
+			if err != nil || got != i*i {
+				t.Errorf("future %d = %d, %v, want %d, nil", i, got, err, i*i)
+			}
+		}
+	})
+
+	t.Run("reports errors and panics", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		pool := NewTypedPool(1, func(_ context.Context, x int) (string, error) {
+			if x == 0 {
+				return "", errBoom
+			}
+			panic("kaboom")
+		})
+		defer pool.Close()
+
+		ctx := context.Background()
+		f0, _ := pool.Submit(ctx, 0)
+		f1, _ := pool.Submit(ctx, 1)
+
+		if _, err := f0.Await(ctx); err != errBoom {
+			t.Errorf("future error = %v, want %v", err, errBoom)
+		}
+		var pe *PanicError
+		if _, err := f1.Await(ctx); !errors.As(err, &pe) {
+			t.Errorf("future error = %v, want *PanicError", err)
+		}
+	})
+
+	t.Run("skips tasks whose context expired in the queue", func(t *testing.T) {
+		var calls int64
+		release := make(chan struct{})
+		pool := NewTypedPool(1, func(_ context.Context, x int) (int, error) {
+			if atomic.AddInt64(&calls, 1) == 1 {
+				<-release
+			}
+			return x, nil
+		})
+		defer pool.Close()
+
+		pool.Submit(context.Background(), 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		f, err := pool.Submit(ctx, 1)
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+		cancel()
+		close(release)
+
+		if _, err := f.Await(context.Background()); err != context.Canceled {
+			t.Errorf("future error = %v, want context.Canceled", err)
+		}
+		pool.Wait()
+		if calls != 1 {
+			t.Errorf("function called %d times, want 1", calls)
+		}
+	})
+
+	t.Run("TrySubmit reports a full queue", func(t *testing.T) {
+		release := make(chan struct{})
+		pool := NewTypedPool(1, func(_ context.Context, x int) (int, error) {
+			<-release
+			return x, nil
+		}, WithQueueCapacity(1))
+		defer pool.Close()
+
+		ctx := context.Background()
+		pool.Submit(ctx, 0)
+		pool.Submit(ctx, 1)
+		if _, err := pool.TrySubmit(ctx, 2); err != ErrQueueFull {
+			t.Errorf("TrySubmit() = %v, want ErrQueueFull", err)
+		}
+		close(release)
+	})
+}
+
+func TestPoolAutoscaling(t *testing.T) {
+	t.Run("grows while the queue backs up", func(t *testing.T) {
+		pool := NewPool(1, WithMaxWorkers(4), WithQueueCapacity(16))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		var active, peak int64
+		for i := 0; i < 8; i++ {
+			pool.Submit(func() {
+				n := atomic.AddInt64(&active, 1)
+				for {
+					old := atomic.LoadInt64(&peak)
+					if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+						break
+					}
+				}
+				<-release
+				atomic.AddInt64(&active, -1)
+			})
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt64(&active) < 4 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := pool.Workers(); got != 4 {
+			t.Errorf("Workers() = %d, want 4", got)
+		}
+		close(release)
+		pool.Wait()
+
+		if peak != 4 {
+			t.Errorf("peak concurrency = %d, want 4", peak)
+		}
+	})
+
+	t.Run("retires idle workers down to the minimum", func(t *testing.T) {
+		pool := NewPool(4, WithMinWorkers(1), WithIdleTimeout(10*time.Millisecond))
+		defer pool.Close()
+
+		deadline := time.Now().Add(time.Second)
+		for pool.Workers() > 1 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := pool.Workers(); got != 1 {
+			t.Fatalf("Workers() = %d, want 1", got)
+		}
+
+		var executed int64
+		pool.Submit(func() { atomic.AddInt64(&executed, 1) })
+		pool.Wait()
+		if executed != 1 {
+			t.Error("pool did not run a task after shrinking")
+		}
+	})
+
+	t.Run("minimum of zero still runs new tasks", func(t *testing.T) {
+		pool := NewPool(1, WithMinWorkers(0), WithIdleTimeout(5*time.Millisecond))
+		defer pool.Close()
+
+		deadline := time.Now().Add(time.Second)
+		for pool.Workers() > 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := pool.Workers(); got != 0 {
+			t.Fatalf("Workers() = %d, want 0", got)
+		}
+
+		var executed int64
+		pool.Submit(func() { atomic.AddInt64(&executed, 1) })
+		pool.Wait()
+		if executed != 1 {
+			t.Error("pool with no workers did not start one for a new task")
+		}
+	})
+
+	t.Run("Resize grows and shrinks", func(t *testing.T) {
+		pool := NewPool(2)
+		defer pool.Close()
+
+		pool.Resize(6)
+		if got := pool.Workers(); got != 6 {
+			t.Errorf("Workers() after Resize(6) = %d, want 6", got)
+		}
+
+		pool.Resize(3)
+		deadline := time.Now().Add(time.Second)
+		for pool.Workers() > 3 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := pool.Workers(); got != 3 {
+			t.Errorf("Workers() after Resize(3) = %d, want 3", got)
+		}
+
+		var counter int64
+		for i := 0; i < 10; i++ {
+			pool.Submit(func() { atomic.AddInt64(&counter, 1) })
+		}
+		pool.Wait()
+		if counter != 10 {
+			t.Errorf("pool executed %d tasks after resizing, want 10", counter)
+		}
+	})
+}
+
+func TestPoolStats(t *testing.T) {
+	t.Run("counts tasks", func(t *testing.T) {
+		pool := NewPool(2, WithPanicHandler(func(*PanicError) {}))
+		defer pool.Close()
+
+		pool.Submit(func() { time.Sleep(time.Millisecond) }, func() {}, func() { panic("boom") })
+		pool.Wait()
+
+		stats := pool.Stats()
+		if stats.Submitted != 3 || stats.Completed != 2 || stats.Failed != 1 {
+			t.Errorf("Stats() = submitted %d, completed %d, failed %d, want 3, 2, 1",
+				stats.Submitted, stats.Completed, stats.Failed)
+		}
+		if stats.Queued != 0 || stats.Running != 0 {
+			t.Errorf("Stats() = queued %d, running %d, want 0, 0", stats.Queued, stats.Running)
+		}
+		if stats.Workers != 2 {
+			t.Errorf("Stats().Workers = %d, want 2", stats.Workers)
+		}
+		if stats.RunTime.Count != 3 || stats.WaitTime.Count != 3 {
+			t.Errorf("histogram counts = %d, %d, want 3, 3", stats.RunTime.Count, stats.WaitTime.Count)
+		}
+		if stats.RunTime.Max < time.Millisecond {
+			t.Errorf("RunTime.Max = %v, want >= 1ms", stats.RunTime.Max)
+		}
+	})
+
+	t.Run("reports queued, running and rejected tasks", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(1))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Submit(func() { close(started); <-release })
+		<-started
+		pool.Submit(func() {})
+		pool.TrySubmit(func() {})
+
+		stats := pool.Stats()
+		if stats.Queued != 1 || stats.Running != 1 || stats.Rejected != 1 {
+			t.Errorf("Stats() = queued %d, running %d, rejected %d, want 1, 1, 1",
+				stats.Queued, stats.Running, stats.Rejected)
+		}
+		close(release)
+	})
+}
+
+func TestPoolHooks(t *testing.T) {
+	var submits, starts, finishes, failures int64
+	var mismatched int64
+	pool := NewPool(2, WithPanicHandler(func(*PanicError) {}), WithHooks(Hooks{
+		OnSubmit: func(ev TaskEvent) {
+			atomic.AddInt64(&submits, 1)
+			if ev.ID == 0 {
+				atomic.AddInt64(&mismatched, 1)
+			}
+		},
+		OnStart: func(TaskEvent) { atomic.AddInt64(&starts, 1) },
+		OnFinish: func(ev TaskEvent) {
+			atomic.AddInt64(&finishes, 1)
+			if ev.Err != nil {
+				atomic.AddInt64(&failures, 1)
+			}
+			if ev.Run < 0 || ev.Wait < 0 {
+				atomic.AddInt64(&mismatched, 1)
+			}
+		},
+	}))
+	defer pool.Close()
+
+	pool.Submit(func() {}, func() {}, func() { panic("boom") })
+	pool.Wait()
+
+	if submits != 3 || starts != 3 || finishes != 3 {
+		t.Errorf("hooks called submit=%d start=%d finish=%d, want 3 each", submits, starts, finishes)
+	}
+	if failures != 1 {
+		t.Errorf("OnFinish saw %d failures, want 1", failures)
+	}
+	if mismatched != 0 {
+		t.Errorf("%d events had invalid fields", mismatched)
+	}
+}
+
+func TestPoolPriority(t *testing.T) {
+	t.Run("runs higher priority first", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(10))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Submit(func() { close(started); <-release })
+		<-started
+
+		var order []int
+		ctx := context.Background()
+		for _, prio := range []int{0, 5, 1, 5, 10} {
+			prio := prio
+			pool.SubmitPriority(ctx, prio, func() { order = append(order, prio) })
+		}
+		close(release)
+		pool.Wait()
+
+		want := []int{10, 5, 5, 1, 0}
+		for i := range want {
+			if order[i] != want[i] {
+				t.Fatalf("execution order = %v, want %v", order, want)
+			}
+		}
+	})
+
+	t.Run("aging lets old low priority tasks run", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		pool := NewPool(1, WithClock(clock), WithQueueCapacity(10), WithPriorityAging(time.Second))
+		defer pool.Close()
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.Submit(func() { close(started); <-release })
+		<-started
+
+		var order []string
+		ctx := context.Background()
+		pool.SubmitPriority(ctx, 0, func() { order = append(order, "old") })
+		// Waiting 6s is worth 6 priority levels, more than the 5 of the next task.
+		clock.Advance(6 * time.Second)
+		pool.SubmitPriority(ctx, 5, func() { order = append(order, "new") })
+		close(release)
+		pool.Wait()
+
+		if len(order) != 2 || order[0] != "old" {
+			t.Errorf("execution order = %v, want [old new]", order)
+		}
+	})
+
+	t.Run("plain submits stay in order", func(t *testing.T) {
+		pool := NewPool(1, WithQueueCapacity(20))
+		defer pool.Close()
+
+		var order []int
+		for i := 0; i < 20; i++ {
+			i := i
+			pool.Submit(func() { order = append(order, i) })
+		}
+		pool.Wait()
+
+		for i, v := range order {
+			if v != i {
+				t.Fatalf("execution order = %v, want ascending", order)
+			}
+		}
+	})
+}
+
+func TestPoolKeyed(t *testing.T) {
+	t.Run("serializes tasks with the same key", func(t *testing.T) {
+		pool := NewPool(4, WithQueueCapacity(32))
+		defer pool.Close()
+
+		var active, overlap int64
+		var order []int
+		ctx := context.Background()
+		for i := 0; i < 10; i++ {
+			i := i
+			pool.SubmitKeyed(ctx, "user-1", func() {
+				if atomic.AddInt64(&active, 1) > 1 {
+					atomic.AddInt64(&overlap, 1)
+				}
+				time.Sleep(time.Millisecond)
+				order = append(order, i)
+				atomic.AddInt64(&active, -1)
+			})
+		}
+		pool.Wait()
+
+		if overlap != 0 {
+			t.Errorf("%d tasks with the same key overlapped", overlap)
+		}
+		for i, v := range order {
+			if v != i {
+				t.Fatalf("keyed order = %v, want ascending", order)
+			}
+		}
+		if stats := pool.Stats(); stats.Completed != 10 {
+			t.Errorf("Stats().Completed = %d, want 10", stats.Completed)
+		}
+	})
+
+	t.Run("runs different keys in parallel", func(t *testing.T) {
+		pool := NewPool(2)
+		defer pool.Close()
+
+		ctx := context.Background()
+		a := make(chan struct{})
+		b := make(chan struct{})
+		pool.SubmitKeyed(ctx, "a", func() { close(a); <-b })
+		pool.SubmitKeyed(ctx, "b", func() { <-a; close(b) })
+
+		done := make(chan struct{})
+		go func() {
+			pool.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("tasks with different keys did not run in parallel")
+		}
+	})
+
+	t.Run("waiting tasks count as queued", func(t *testing.T) {
+		pool := NewPool(2, WithQueueCapacity(4))
+		defer pool.Close()
+
+		ctx := context.Background()
+		release := make(chan struct{})
+		started := make(chan struct{})
+		pool.SubmitKeyed(ctx, "k", func() { close(started); <-release })
+		<-started
+		pool.SubmitKeyed(ctx, "k", func() {})
+		pool.SubmitKeyed(ctx, "k", func() {})
+
+		if stats := pool.Stats(); stats.Queued != 2 {
+			t.Errorf("Stats().Queued = %d, want 2", stats.Queued)
+		}
+		close(release)
+		pool.Wait()
+		if len(pool.keys) != 0 {
+			t.Errorf("pool still tracks %d keys after draining", len(pool.keys))
+		}
+	})
+}
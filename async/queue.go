@@ -0,0 +1,251 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueClosed is returned when pushing to a closed Queue, and when popping
+// from one that has been closed and emptied.
+var ErrQueueClosed = errors.New("async: queue is closed")
+
+// OverflowPolicy selects what happens to a value added to a full queue, be it
+// a Queue or the queue of an EventBus subscriber.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the producer wait for room in the queue, or for its
+	// context to be done.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued value to make room.
+	OverflowDropOldest
+	// OverflowDropNewest discards the value being added.
+	OverflowDropNewest
+	// OverflowError rejects the value being added with ErrQueueFull.
+	OverflowError
+)
+
+// Queue is a bounded FIFO queue between goroutines, like a buffered channel
+// whose behaviour when full is explicit: producers block, lose the newest or
+// the oldest value, or get ErrQueueFull. Unlike a channel, it can be closed
+// while producers are blocked, and both sides can wait with a context.
+// It is safe for concurrent use.
+//
+// Example:
+//
+//	// Keep only the 100 most recent samples if the consumer falls behind.
+//	samples := async.NewQueue[Sample](100, async.OverflowDropOldest)
+//
+//	go func() {
+//		defer samples.Close()
+//		for s := range sensor.Readings() {
+//			samples.Push(ctx, s)
+//		}
+//	}()
+//
+//	for {
+//		s, err := samples.Pop(ctx)
+//		if err != nil {
+//			break // ErrQueueClosed once drained, or ctx.Err()
+//		}
+//		store(s)
+//	}
+type Queue[T any] struct {
+	policy OverflowPolicy
+
+	mu       sync.Mutex
+	buf      []T // ring buffer of len(buf) == capacity
+	head     int // index of the oldest value in buf
+	n        int // number of values in buf
+	closed   bool
+	dropped  uint64
+	notEmpty chan struct{} // closed and replaced when a value is added or the queue is closed
+	notFull  chan struct{} // closed and replaced when a value is removed or the queue is closed
+}
+
+// NewQueue returns an empty Queue holding up to capacity values, at least one,
+// that applies policy when it is full.
+func NewQueue[T any](capacity int, policy OverflowPolicy) *Queue[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Queue[T]{
+		policy:   policy,
+		buf:      make([]T, capacity),
+		notEmpty: make(chan struct{}),
+		notFull:  make(chan struct{}),
+	}
+}
+
+// Push adds v to the back of the queue. If the queue is full, it applies the
+// queue's policy: under OverflowBlock it waits for room and returns ctx.Err()
+// if ctx is done first, under OverflowError it returns ErrQueueFull, and under
+// the drop policies it returns nil whichever value is lost. It returns
+// ErrQueueClosed if the queue is closed, including while it waits.
+func (q *Queue[T]) Push(ctx context.Context, v T) error {
+	return q.push(ctx, v, true)
+}
+
+// TryPush is like Push but never blocks: under OverflowBlock it returns
+// ErrQueueFull instead of waiting for room.
+func (q *Queue[T]) TryPush(v T) error {
+	return q.push(context.Background(), v, false)
+}
+
+// push adds v to the queue, waiting for room if block is set and the policy is
+// OverflowBlock.
+func (q *Queue[T]) push(ctx context.Context, v T, block bool) error {
+	q.mu.Lock()
+	for !q.closed && q.n == len(q.buf) {
+		switch q.policy {
+		case OverflowDropNewest:
+			q.dropped++
+			q.mu.Unlock()
+			return nil
+		case OverflowDropOldest:
+			q.removeHead()
+			q.dropped++
+		case OverflowBlock:
+			if block {
+				notFull := q.notFull
+				q.mu.Unlock()
+				select {
+				case <-notFull:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				q.mu.Lock()
+				continue
+			}
+			fallthrough
+		default:
+			q.dropped++
+			q.mu.Unlock()
+			return ErrQueueFull
+		}
+	}
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	q.buf[(q.head+q.n)%len(q.buf)] = v
+	q.n++
+	close(q.notEmpty)
+	q.notEmpty = make(chan struct{})
+	return nil
+}
+
+// Pop removes and returns the value at the front of the queue, waiting for one
+// to be pushed if it is empty. It returns ctx.Err() if ctx is done first, and
+// ErrQueueClosed once the queue is closed and every value has been popped.
+func (q *Queue[T]) Pop(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	for q.n == 0 {
+		if q.closed {
+			q.mu.Unlock()
+			var zero T
+			return zero, ErrQueueClosed
+		}
+		notEmpty := q.notEmpty
+		q.mu.Unlock()
+		select {
+		case <-notEmpty:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		q.mu.Lock()
+	}
+	defer q.mu.Unlock()
+	return q.removeHead(), nil
+}
+
+// TryPop is like Pop but never blocks: it reports false if the queue is empty.
+func (q *Queue[T]) TryPop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.n == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.removeHead(), true
+}
+
+// Chan returns a channel that receives the values popped from the queue, for
+// use in select statements and with the pipeline helpers. The channel is
+// closed once the queue is closed and drained, or ctx is done; cancel ctx when
+// the channel is abandoned early. Up to one popped value may wait in the
+// goroutine feeding the channel, and is lost if ctx is done.
+//
+// Example:
+//
+//	results, errc := async.MapStage(ctx, queue.Chan(ctx), 4, process)
+func (q *Queue[T]) Chan(ctx context.Context) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, err := q.Pop(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Close closes the queue: Push fails with ErrQueueClosed from now on, including
+// for blocked producers, while Pop keeps returning the queued values until the
+// queue is empty. Close is idempotent.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.notEmpty)
+	close(q.notFull)
+}
+
+// Len returns the number of values in the queue.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// Cap returns the maximum number of values the queue holds.
+func (q *Queue[T]) Cap() int {
+	return len(q.buf)
+}
+
+// Dropped returns the number of values lost or rejected because the queue was
+// full, excluding those Push gave up on because its context was done.
+func (q *Queue[T]) Dropped() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// removeHead removes and returns the oldest value. q.mu must be held and the
+// queue must not be empty.
+func (q *Queue[T]) removeHead() T {
+	v := q.buf[q.head]
+	var zero T
+	q.buf[q.head] = zero
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	if !q.closed {
+		close(q.notFull)
+		q.notFull = make(chan struct{})
+	}
+	return v
+}
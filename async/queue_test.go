@@ -0,0 +1,150 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drainQueue pops every value left in q.
+func drainQueue[T any](q *Queue[T]) []T {
+	var got []T
+	for {
+		v, ok := q.TryPop()
+		if !ok {
+			return got
+		}
+		got = append(got, v)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("FIFO with length and capacity", func(t *testing.T) {
+		q := NewQueue[int](3, OverflowBlock)
+		for i := 1; i <= 3; i++ {
+			if err := q.Push(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if q.Len() != 3 || q.Cap() != 3 {
+			t.Errorf("Len(), Cap() = %d, %d, want 3, 3", q.Len(), q.Cap())
+		}
+		if v, err := q.Pop(context.Background()); v != 1 || err != nil {
+			t.Errorf("Pop() = %v, %v, want 1, nil", v, err)
+		}
+		_ = q.Push(context.Background(), 4) // wraps around the ring
+		if got := drainQueue(q); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+			t.Errorf("popped %v, want [2 3 4]", got)
+		}
+	})
+
+	t.Run("overflow policies", func(t *testing.T) {
+		tests := []struct {
+			policy  OverflowPolicy
+			want    []int
+			wantErr error
+		}{
+			{OverflowDropNewest, []int{1, 2}, nil},
+			{OverflowDropOldest, []int{2, 3}, nil},
+			{OverflowError, []int{1, 2}, ErrQueueFull},
+		}
+		for _, tt := range tests {
+			q := NewQueue[int](2, tt.policy)
+			_ = q.Push(context.Background(), 1)
+			_ = q.Push(context.Background(), 2)
+			if err := q.Push(context.Background(), 3); err != tt.wantErr {
+				t.Errorf("policy %d: Push() error = %v, want %v", tt.policy, err, tt.wantErr)
+			}
+			if q.Dropped() != 1 {
+				t.Errorf("policy %d: Dropped() = %d, want 1", tt.policy, q.Dropped())
+			}
+			if got := drainQueue(q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("policy %d: popped %v, want %v", tt.policy, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("block waits for room or the context", func(t *testing.T) {
+		q := NewQueue[int](1, OverflowBlock)
+		_ = q.Push(context.Background(), 1)
+		if err := q.TryPush(2); err != ErrQueueFull {
+			t.Errorf("TryPush() error = %v, want ErrQueueFull", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := q.Push(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Push() error = %v, want DeadlineExceeded", err)
+		}
+
+		errc := make(chan error, 1)
+		go func() { errc <- q.Push(context.Background(), 3) }()
+		time.Sleep(10 * time.Millisecond)
+		if v, _ := q.Pop(context.Background()); v != 1 {
+			t.Errorf("Pop() = %v, want 1", v)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("Push() error = %v, want nil once there is room", err)
+		}
+		if v, _ := q.TryPop(); v != 3 {
+			t.Errorf("TryPop() = %v, want 3", v)
+		}
+	})
+
+	t.Run("pop waits for a value or the context", func(t *testing.T) {
+		q := NewQueue[string](1, OverflowBlock)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := q.Pop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Pop() error = %v, want DeadlineExceeded", err)
+		}
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = q.Push(context.Background(), "hello")
+		}()
+		if v, err := q.Pop(context.Background()); v != "hello" || err != nil {
+			t.Errorf("Pop() = %q, %v, want hello, nil", v, err)
+		}
+	})
+
+	t.Run("close rejects pushes and drains pops", func(t *testing.T) {
+		q := NewQueue[int](1, OverflowBlock)
+		_ = q.Push(context.Background(), 1)
+		errc := make(chan error, 1)
+		go func() { errc <- q.Push(context.Background(), 2) }()
+		time.Sleep(10 * time.Millisecond)
+
+		q.Close()
+		q.Close()
+		if err := <-errc; err != ErrQueueClosed {
+			t.Errorf("blocked Push() error = %v, want ErrQueueClosed", err)
+		}
+		if err := q.Push(context.Background(), 3); err != ErrQueueClosed {
+			t.Errorf("Push() error = %v, want ErrQueueClosed", err)
+		}
+		if v, err := q.Pop(context.Background()); v != 1 || err != nil {
+			t.Errorf("Pop() = %v, %v, want the queued 1", v, err)
+		}
+		if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+			t.Errorf("Pop() error = %v, want ErrQueueClosed once drained", err)
+		}
+	})
+
+	t.Run("Chan", func(t *testing.T) {
+		q := NewQueue[int](4, OverflowBlock)
+		for i := 0; i < 4; i++ {
+			_ = q.Push(context.Background(), i)
+		}
+		q.Close()
+		var got []int
+		for v := range q.Chan(context.Background()) {
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+			t.Errorf("received %v, want [0 1 2 3]", got)
+		}
+	})
+}
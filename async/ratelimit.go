@@ -0,0 +1,549 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket: it holds up to burst tokens, refills them at
+// rate tokens per second, and every event spends one token. Unlike Throttle,
+// which drops calls, it lets callers wait for their turn so that every call
+// eventually goes through at a bounded rate.
+//
+// A rate of math.Inf(1) allows every event; a rate of zero or less allows only
+// the tokens the bucket already holds. It is safe for concurrent use.
+//
+// Example:
+//
+//	limiter := async.NewRateLimiter(100, 10) // 100 requests/s, bursts of 10
+//	for _, req := range requests {
+//		if err := limiter.Wait(ctx); err != nil {
+//			return err
+//		}
+//		send(req)
+//	}
+type RateLimiter struct {
+	clock Clock
+
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	tokens    float64
+	last      time.Time // time tokens was last updated
+	lastEvent time.Time // latest time a reservation may act
+}
+
+// NewRateLimiter returns a RateLimiter that allows rate events per second with
+// bursts of up to burst events. The bucket starts full. The tokens are refilled
+// on the clock set with WithClock.
+func NewRateLimiter(rate float64, burst int, opts ...Option) *RateLimiter {
+	if burst < 0 {
+		burst = 0
+	}
+	clock := applyOptions(opts).clock
+	return &RateLimiter{clock: clock, rate: rate, burst: burst, tokens: float64(burst), last: clock.Now()}
+}
+
+// Reservation is a promise of tokens from a RateLimiter, returned by Reserve.
+// The caller must wait Delay before acting, or Cancel the reservation to give
+// the tokens back.
+type Reservation struct {
+	ok        bool
+	limiter   *RateLimiter
+	tokens    int
+	timeToAct time.Time
+}
+
+// Allow reports whether an event may happen now, spending a token if it may.
+//
+// Example:
+//
+//	if !limiter.Allow() {
+//		http.Error(w, "too many requests", http.StatusTooManyRequests)
+//		return
+//	}
+func (l *RateLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, spending n tokens if they may.
+func (l *RateLimiter) AllowN(n int) bool {
+	return l.reserve(l.clock.Now(), n, 0).ok
+}
+
+// Reserve reserves a token and returns a Reservation telling how long the caller
+// must wait before acting. The reservation is not OK if the limiter can never
+// grant it, for example when its rate is zero and the bucket is empty.
+//
+// Example:
+//
+//	r := limiter.Reserve()
+//	if !r.OK() {
+//		return errRateLimited
+//	}
+//	time.Sleep(r.Delay())
+//	send(req)
+func (l *RateLimiter) Reserve() *Reservation {
+	return l.ReserveN(1)
+}
+
+// ReserveN is like Reserve for n tokens. The reservation is not OK if n exceeds
+// the burst size.
+func (l *RateLimiter) ReserveN(n int) *Reservation {
+	return l.reserve(l.clock.Now(), n, time.Duration(math.MaxInt64))
+}
+
+// Wait blocks until a token is available or ctx is done. It returns an error
+// without waiting if ctx's deadline would pass before the token is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available or ctx is done. It returns an error
+// if n exceeds the burst size, and without waiting if ctx's deadline would pass
+// before the tokens are available.
+//
+// Example:
+//
+//	// Spend one token per kilobyte sent.
+//	if err := limiter.WaitN(ctx, len(chunk)/1024); err != nil {
+//		return err
+//	}
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	rate, burst := l.rate, l.burst
+	l.mu.Unlock()
+	if n > burst && !math.IsInf(rate, 1) {
+		return fmt.Errorf("async: rate limiter wait for %d tokens exceeds burst of %d", n, burst)
+	}
+
+	now := l.clock.Now()
+	maxWait := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(now)
+	}
+	r := l.reserve(now, n, maxWait)
+	if !r.ok {
+		return fmt.Errorf("async: rate limiter wait for %d tokens would exceed the context deadline: %w",
+			n, context.DeadlineExceeded)
+	}
+
+	if err := sleepCtx(ctx, l.clock, r.delayFrom(now)); err != nil {
+		r.cancelAt(l.clock.Now())
+		return err
+	}
+	return nil
+}
+
+// SetRate changes the rate at which tokens are refilled. Tokens accumulated so
+// far are kept.
+//
+// Example:
+//
+//	// Back off while the downstream service reports overload.
+//	limiter.SetRate(limiter.Rate() / 2)
+func (l *RateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.tokens = l.advance(now)
+	l.last = now
+	l.rate = rate
+}
+
+// SetBurst changes the size of the bucket, dropping tokens above the new size.
+func (l *RateLimiter) SetBurst(burst int) {
+	if burst < 0 {
+		burst = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.tokens = l.advance(now)
+	l.last = now
+	l.burst = burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+// Rate returns the number of tokens refilled per second.
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Burst returns the size of the bucket.
+func (l *RateLimiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// Tokens returns the number of tokens currently available. It is negative while
+// reservations are waiting for tokens that have not been refilled yet.
+func (l *RateLimiter) Tokens() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.advance(l.clock.Now())
+}
+
+// reserve takes n tokens at now if the wait for them does not exceed maxWait.
+func (l *RateLimiter) reserve(now time.Time, n int, maxWait time.Duration) *Reservation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if math.IsInf(l.rate, 1) {
+		return &Reservation{ok: true, limiter: l, tokens: n, timeToAct: now}
+	}
+	if n > l.burst {
+		return &Reservation{limiter: l, tokens: n}
+	}
+
+	tokens := l.advance(now) - float64(n)
+	var wait time.Duration
+	if tokens < 0 {
+		if l.rate <= 0 {
+			return &Reservation{limiter: l, tokens: n}
+		}
+		wait = l.durationFromTokens(-tokens)
+	}
+	if wait > maxWait {
+		return &Reservation{limiter: l, tokens: n}
+	}
+
+	r := &Reservation{ok: true, limiter: l, tokens: n, timeToAct: now.Add(wait)}
+	l.tokens = tokens
+	l.last = now
+	if r.timeToAct.After(l.lastEvent) {
+		l.lastEvent = r.timeToAct
+	}
+	return r
+}
+
+// advance returns the number of tokens available at now. l.mu must be held.
+func (l *RateLimiter) advance(now time.Time) float64 {
+	elapsed := now.Sub(l.last)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	tokens := l.tokens
+	if l.rate > 0 {
+		tokens += elapsed.Seconds() * l.rate
+	}
+	if burst := float64(l.burst); tokens > burst {
+		tokens = burst
+	}
+	return tokens
+}
+
+// durationFromTokens returns the time needed to refill the given number of tokens.
+// l.mu must be held.
+func (l *RateLimiter) durationFromTokens(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	seconds := tokens / l.rate
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// OK reports whether the limiter can grant the reservation. A reservation that
+// is not OK holds no tokens and must not be acted upon.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller must wait before acting on the reservation.
+// It returns zero if the tokens are already available.
+func (r *Reservation) Delay() time.Duration {
+	return r.delayFrom(r.limiter.clock.Now())
+}
+
+// Cancel gives the reserved tokens back to the limiter, as far as that does not
+// affect reservations made since. It has no effect once the reservation's time to
+// act has passed.
+func (r *Reservation) Cancel() {
+	r.cancelAt(r.limiter.clock.Now())
+}
+
+// delayFrom returns the wait before acting on the reservation from now.
+func (r *Reservation) delayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	delay := r.timeToAct.Sub(now)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// cancelAt returns the reservation's tokens to the limiter at now.
+func (r *Reservation) cancelAt(now time.Time) {
+	if !r.ok {
+		return
+	}
+	r.ok = false
+
+	l := r.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if math.IsInf(l.rate, 1) || !r.timeToAct.After(now) {
+		return
+	}
+
+	// Tokens reserved after this reservation stay taken; only the rest is returned.
+	restore := float64(r.tokens) - l.tokensFromDuration(l.lastEvent.Sub(r.timeToAct))
+	if restore <= 0 {
+		return
+	}
+	l.tokens = l.advance(now) + restore
+	if burst := float64(l.burst); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	if r.timeToAct.Equal(l.lastEvent) {
+		if prev := r.timeToAct.Add(-l.durationFromTokens(float64(r.tokens))); !prev.Before(now) {
+			l.lastEvent = prev
+		}
+	}
+}
+
+// tokensFromDuration returns the number of tokens refilled over d. l.mu must be held.
+func (l *RateLimiter) tokensFromDuration(d time.Duration) float64 {
+	if l.rate <= 0 || d <= 0 {
+		return 0
+	}
+	return d.Seconds() * l.rate
+}
+
+// SlidingWindowLimiter allows at most limit events within any window of the
+// given length. It remembers the time of every event in the window, which makes
+// it exact at the cost of memory proportional to limit; prefer RateLimiter for
+// large limits. It is safe for concurrent use.
+//
+// Example:
+//
+//	// At most 5 login attempts per minute.
+//	limiter := async.NewSlidingWindowLimiter(5, time.Minute)
+//	if !limiter.Allow() {
+//		return errTooManyAttempts
+//	}
+type SlidingWindowLimiter struct {
+	clock Clock
+
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events []time.Time // oldest first
+}
+
+// NewSlidingWindowLimiter returns a limiter allowing limit events per window,
+// measured on the clock set with WithClock.
+func NewSlidingWindowLimiter(limit int, window time.Duration, opts ...Option) *SlidingWindowLimiter {
+	if limit < 0 {
+		limit = 0
+	}
+	return &SlidingWindowLimiter{
+		clock:  applyOptions(opts).clock,
+		limit:  limit,
+		window: window,
+		events: make([]time.Time, 0, limit),
+	}
+}
+
+// Allow reports whether an event may happen now and records it if it may.
+func (l *SlidingWindowLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ok, _ := l.take(l.clock.Now())
+	return ok
+}
+
+// Wait blocks until an event may happen and records it, or until ctx is done.
+//
+// Example:
+//
+//	if err := limiter.Wait(ctx); err != nil {
+//		return err
+//	}
+func (l *SlidingWindowLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		ok, wait := l.take(l.clock.Now())
+		l.mu.Unlock()
+		if ok {
+			return nil
+		}
+		if l.limit == 0 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if err := sleepCtx(ctx, l.clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Remaining returns the number of events that may happen now.
+func (l *SlidingWindowLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(l.clock.Now())
+	return l.limit - len(l.events)
+}
+
+// take records an event at now if the window has room, and otherwise returns
+// how long until the oldest event leaves the window. l.mu must be held.
+func (l *SlidingWindowLimiter) take(now time.Time) (bool, time.Duration) {
+	l.prune(now)
+	if len(l.events) < l.limit {
+		l.events = append(l.events, now)
+		return true, 0
+	}
+	if l.limit == 0 {
+		return false, 0
+	}
+	return false, l.events[0].Add(l.window).Sub(now)
+}
+
+// prune forgets the events that left the window ending at now. l.mu must be held.
+func (l *SlidingWindowLimiter) prune(now time.Time) {
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.events) && !l.events[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		l.events = append(l.events[:0], l.events[i:]...)
+	}
+}
+
+// KeyedLimiter keeps a separate RateLimiter per key, for example one bucket per
+// tenant or client address. Buckets are created on first use and evicted once
+// they have been idle, and therefore full, for the idle timeout, which defaults
+// to 30 seconds and is set with WithIdleTimeout. It is safe for concurrent use.
+//
+// Example:
+//
+//	limiter := async.NewKeyedLimiter[string](10, 20, async.WithIdleTimeout(time.Minute))
+//	if !limiter.Allow(tenantID) {
+//		http.Error(w, "too many requests", http.StatusTooManyRequests)
+//		return
+//	}
+type KeyedLimiter[K comparable] struct {
+	rate        float64
+	burst       int
+	idleTimeout time.Duration
+	clock       Clock
+
+	mu        sync.Mutex
+	limiters  map[K]*keyedBucket
+	lastSweep time.Time
+}
+
+// keyedBucket is the limiter of one key and the last time it was used.
+type keyedBucket struct {
+	limiter  *RateLimiter
+	lastUsed time.Time
+}
+
+// NewKeyedLimiter returns a KeyedLimiter whose buckets allow rate events per
+// second with bursts of up to burst events.
+func NewKeyedLimiter[K comparable](rate float64, burst int, opts ...Option) *KeyedLimiter[K] {
+	o := applyOptions(opts)
+	idleTimeout := o.idleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+	return &KeyedLimiter[K]{
+		rate:        rate,
+		burst:       burst,
+		idleTimeout: idleTimeout,
+		clock:       o.clock,
+		limiters:    make(map[K]*keyedBucket),
+		lastSweep:   o.clock.Now(),
+	}
+}
+
+// Allow reports whether an event for key may happen now, spending a token from
+// key's bucket if it may.
+func (k *KeyedLimiter[K]) Allow(key K) bool {
+	return k.limiter(key).Allow()
+}
+
+// Reserve reserves a token from key's bucket, see RateLimiter.Reserve.
+func (k *KeyedLimiter[K]) Reserve(key K) *Reservation {
+	return k.limiter(key).Reserve()
+}
+
+// Wait blocks until a token from key's bucket is available or ctx is done,
+// see RateLimiter.Wait.
+func (k *KeyedLimiter[K]) Wait(ctx context.Context, key K) error {
+	return k.limiter(key).Wait(ctx)
+}
+
+// Len returns the number of buckets currently kept.
+func (k *KeyedLimiter[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.limiters)
+}
+
+// limiter returns key's bucket, creating it if needed, and evicts idle buckets
+// at most once per idle timeout.
+func (k *KeyedLimiter[K]) limiter(key K) *RateLimiter {
+	now := k.clock.Now()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if now.Sub(k.lastSweep) >= k.idleTimeout {
+		k.evict(now)
+		k.lastSweep = now
+	}
+
+	b, ok := k.limiters[key]
+	if !ok {
+		b = &keyedBucket{limiter: NewRateLimiter(k.rate, k.burst, WithClock(k.clock))}
+		k.limiters[key] = b
+	}
+	b.lastUsed = now
+	return b.limiter
+}
+
+// evict removes the buckets that have been idle for the idle timeout. A bucket
+// is only removed once it is full again, so that eviction never grants more
+// events than the bucket would have. k.mu must be held.
+func (k *KeyedLimiter[K]) evict(now time.Time) {
+	for key, b := range k.limiters {
+		if now.Sub(b.lastUsed) < k.idleTimeout {
+			continue
+		}
+
+		b.limiter.mu.Lock()
+		full := b.limiter.advance(now) >= float64(b.limiter.burst)
+		b.limiter.mu.Unlock()
+		if full {
+			delete(k.limiters, key)
+		}
+	}
+}
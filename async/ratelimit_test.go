@@ -0,0 +1,297 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("refills on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		l := NewRateLimiter(1, 2, WithClock(clock))
+		if !l.Allow() || !l.Allow() || l.Allow() {
+			t.Fatal("want a burst of 2")
+		}
+		if d := l.Reserve().Delay(); d != time.Second {
+			t.Errorf("Delay() = %v, want 1s", d)
+		}
+
+		clock.Advance(2 * time.Second)
+		if !l.Allow() {
+			t.Error("Allow() = false after the refill")
+		}
+
+		errc := make(chan error, 1)
+		go func() { errc <- l.Wait(context.Background()) }()
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if err := <-errc; err != nil {
+			t.Errorf("Wait() error = %v", err)
+		}
+	})
+
+	t.Run("allows bursts then refills", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(100, 3, WithClock(clock))
+
+		for i := 0; i < 3; i++ {
+			if !limiter.Allow() {
+				t.Fatalf("Allow() #%d = false, want true", i)
+			}
+		}
+		if limiter.Allow() {
+			t.Fatal("Allow() on empty bucket = true, want false")
+		}
+
+		clock.Advance(10 * time.Millisecond)
+		if !limiter.Allow() {
+			t.Error("Allow() after refill = false, want true")
+		}
+	})
+
+	t.Run("AllowN", func(t *testing.T) {
+		limiter := NewRateLimiter(1, 5)
+		if !limiter.AllowN(5) {
+			t.Error("AllowN(5) = false, want true")
+		}
+		if limiter.AllowN(6) {
+			t.Error("AllowN(6) above burst = true, want false")
+		}
+	})
+
+	t.Run("Reserve reports the delay", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(10, 1, WithClock(clock))
+		if r := limiter.Reserve(); !r.OK() || r.Delay() != 0 {
+			t.Fatalf("first Reserve() = ok %v, delay %v, want ok without delay", r.OK(), r.Delay())
+		}
+
+		r := limiter.Reserve()
+		if !r.OK() {
+			t.Fatal("second Reserve() not OK")
+		}
+		if d := r.Delay(); d != 100*time.Millisecond {
+			t.Errorf("Delay() = %v, want 100ms", d)
+		}
+
+		if r := limiter.ReserveN(2); r.OK() {
+			t.Error("ReserveN(2) above burst is OK, want not OK")
+		}
+	})
+
+	t.Run("Cancel returns tokens", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(1, 1, WithClock(clock))
+		limiter.Allow()
+
+		r := limiter.Reserve()
+		if limiter.Tokens() >= 0 {
+			t.Fatalf("Tokens() with pending reservation = %v, want negative", limiter.Tokens())
+		}
+		r.Cancel()
+		if tokens := limiter.Tokens(); tokens != 0 {
+			t.Errorf("Tokens() after Cancel = %v, want 0", tokens)
+		}
+	})
+
+	t.Run("Wait paces callers", func(t *testing.T) {
+		limiter := NewRateLimiter(50, 1)
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait() = %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+			t.Errorf("5 waits at 50/s took %v, want at least 80ms", elapsed)
+		}
+	})
+
+	t.Run("Wait fails fast past the deadline", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(1, 1, WithClock(clock))
+		limiter.Allow()
+
+		ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+		defer cancel()
+		err := limiter.Wait(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Wait() = %v, want context.DeadlineExceeded", err)
+		}
+		if ctx.Err() != nil {
+			t.Error("Wait() returned once the deadline passed, want an immediate error")
+		}
+	})
+
+	t.Run("Wait stops on cancellation", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(5, 1, WithClock(clock))
+		limiter.Allow()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() { errc <- limiter.Wait(ctx) }()
+		clock.BlockUntil(1)
+		cancel()
+		if err := <-errc; !errors.Is(err, context.Canceled) {
+			t.Errorf("Wait() = %v, want context.Canceled", err)
+		}
+		if tokens := limiter.Tokens(); tokens != 0 {
+			t.Errorf("Tokens() after canceled Wait = %v, want 0 with the reservation returned", tokens)
+		}
+	})
+
+	t.Run("WaitN above burst", func(t *testing.T) {
+		limiter := NewRateLimiter(10, 2)
+		if err := limiter.WaitN(context.Background(), 3); err == nil {
+			t.Error("WaitN(3) above burst = nil, want error")
+		}
+	})
+
+	t.Run("SetRate and SetBurst", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		limiter := NewRateLimiter(1, 1, WithClock(clock))
+		limiter.Allow()
+		limiter.SetRate(1000)
+		clock.Advance(5 * time.Millisecond)
+		if !limiter.Allow() {
+			t.Error("Allow() after raising rate = false, want true")
+		}
+		if limiter.Rate() != 1000 {
+			t.Errorf("Rate() = %v, want 1000", limiter.Rate())
+		}
+
+		limiter.SetBurst(10)
+		clock.Advance(20 * time.Millisecond)
+		if !limiter.AllowN(10) {
+			t.Error("AllowN(10) after raising burst = false, want true")
+		}
+		if limiter.Burst() != 10 {
+			t.Errorf("Burst() = %v, want 10", limiter.Burst())
+		}
+	})
+
+	t.Run("infinite and zero rates", func(t *testing.T) {
+		unlimited := NewRateLimiter(math.Inf(1), 0)
+		for i := 0; i < 100; i++ {
+			if !unlimited.Allow() {
+				t.Fatal("Allow() with infinite rate = false, want true")
+			}
+		}
+
+		stopped := NewRateLimiter(0, 1)
+		if !stopped.Allow() {
+			t.Error("Allow() with zero rate and a full bucket = false, want true")
+		}
+		if r := stopped.Reserve(); r.OK() {
+			t.Error("Reserve() with zero rate and an empty bucket is OK, want not OK")
+		}
+	})
+
+	t.Run("concurrent callers", func(t *testing.T) {
+		limiter := NewRateLimiter(1, 10)
+		var allowed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if limiter.Allow() {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if allowed != 10 {
+			t.Errorf("allowed %d concurrent calls, want 10", allowed)
+		}
+	})
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	t.Run("slides on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		l := NewSlidingWindowLimiter(2, time.Minute, WithClock(clock))
+		l.Allow()
+		clock.Advance(30 * time.Second)
+		l.Allow()
+		if l.Allow() {
+			t.Fatal("Allow() = true with a full window")
+		}
+		clock.Advance(30 * time.Second)
+		if got := l.Remaining(); got != 1 {
+			t.Errorf("Remaining() = %d, want 1", got)
+		}
+	})
+
+	clock := NewFakeClock(time.Now())
+	limiter := NewSlidingWindowLimiter(3, 50*time.Millisecond, WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("Allow() #%d = false, want true", i)
+		}
+	}
+	if limiter.Allow() {
+		t.Fatal("Allow() over the limit = true, want false")
+	}
+	if limiter.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want 0", limiter.Remaining())
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- limiter.Wait(context.Background()) }()
+	clock.BlockUntil(1)
+	clock.Advance(50 * time.Millisecond)
+	if err := <-errc; err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter.Allow()
+	limiter.Allow()
+	go func() { errc <- limiter.Wait(ctx) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() = %v, want context.Canceled", err)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if limiter.Remaining() != 3 {
+		t.Errorf("Remaining() after the window = %d, want 3", limiter.Remaining())
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	limiter := NewKeyedLimiter[string](100, 1, WithClock(clock), WithIdleTimeout(20*time.Millisecond))
+
+	if !limiter.Allow("a") || !limiter.Allow("b") {
+		t.Fatal("Allow() on fresh keys = false, want true")
+	}
+	if limiter.Allow("a") {
+		t.Error("Allow() on exhausted key = true, want false")
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- limiter.Wait(context.Background(), "a") }()
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+	if err := <-errc; err != nil {
+		t.Errorf("Wait() = %v", err)
+	}
+	if limiter.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", limiter.Len())
+	}
+
+	clock.Advance(40 * time.Millisecond)
+	limiter.Allow("c")
+	if limiter.Len() != 1 {
+		t.Errorf("Len() after idle eviction = %d, want 1", limiter.Len())
+	}
+}
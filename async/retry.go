@@ -0,0 +1,284 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Jitter selects how a RetryPolicy randomizes its backoff delays, which keeps
+// many clients that failed at the same moment from retrying in lockstep.
+type Jitter int
+
+const (
+	// NoJitter uses the exponential delay as is.
+	NoJitter Jitter = iota
+	// FullJitter picks a random delay between zero and the exponential delay.
+	FullJitter
+	// EqualJitter keeps half of the exponential delay and randomizes the other half.
+	EqualJitter
+	// DecorrelatedJitter picks a random delay between the initial delay and three
+	// times the previous delay, capped at MaxDelay.
+	DecorrelatedJitter
+)
+
+// RetryPolicy describes how RetryCtx retries a failing operation.
+// The zero value retries immediately and without limit, so most policies set
+// at least MaxAttempts or MaxElapsed and an InitialDelay.
+//
+// Example:
+//
+//	policy := async.RetryPolicy{
+//		MaxAttempts:  5,
+//		InitialDelay: 100 * time.Millisecond,
+//		MaxDelay:     5 * time.Second,
+//		Jitter:       async.FullJitter,
+//		Retryable:    func(err error) bool { return !errors.Is(err, ErrNotFound) },
+//	}
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls, including the first one.
+	// Zero or less means no limit.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between two attempts. Zero means no cap.
+	MaxDelay time.Duration
+	// Multiplier is the factor the delay grows by after every retry.
+	// Zero or less selects the default of 2.
+	Multiplier float64
+	// Jitter randomizes the delays. The default is NoJitter.
+	Jitter Jitter
+	// MaxElapsed stops retrying once the next attempt would start more than
+	// MaxElapsed after the first one. Zero means no limit.
+	MaxElapsed time.Duration
+	// Retryable reports whether an error is worth retrying. If nil, every error
+	// is retried except those wrapped with Permanent.
+	Retryable func(error) bool
+	// OnRetry, if set, is called before waiting for the next attempt with the
+	// number of the attempt that failed, its error and the delay about to be applied.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// Retry executes a function up to maxAttempts times with exponential backoff:
+// it waits initialDelay before the first retry and doubles the delay after each one.
+// Returns the result of the first successful attempt or the last error.
+// Use RetryCtx for jitter, error classification and cancellation. The delays
+// are measured on the clock set with WithClock.
+//
+// Example:
+//
+//	result, err := async.Retry(func() (string, error) {
+//		// Some operation that might fail
+//		return fetchDataFromAPI()
+//	}, 3, 100*time.Millisecond)
+//
+//	if err != nil {
+//		fmt.Printf("All retry attempts failed: %v\n", err)
+//	}
+func Retry[T any](f func() (T, error), maxAttempts int, initialDelay time.Duration, opts ...Option) (T, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	policy := RetryPolicy{MaxAttempts: maxAttempts, InitialDelay: initialDelay}
+	return RetryCtx(context.Background(), policy, func(context.Context) (T, error) {
+		return f()
+	}, opts...)
+}
+
+// RetryCtx calls f until it succeeds, returns an error that is not retryable,
+// the policy's attempt or elapsed-time budget is exhausted, or ctx is done.
+//
+// A non-retryable error is returned as is. An error marked with Permanent is
+// returned without the mark, keeping any wrapping added around it.
+// When the budget is exhausted the last error is returned wrapped with the number
+// of attempts, and when ctx is done the returned error wraps both ctx.Err() and the
+// last error. An error carrying a delay, see RetryAfter, overrides the computed backoff.
+// The delays and MaxElapsed are measured on the clock set with WithClock.
+//
+// Example:
+//
+//	body, err := async.RetryCtx(ctx, policy, func(ctx context.Context) ([]byte, error) {
+//		resp, err := client.Get(ctx, url)
+//		if err != nil {
+//			return nil, err
+//		}
+//		if resp.StatusCode == http.StatusTooManyRequests {
+//			return nil, async.RetryAfter(errThrottled, parseRetryAfter(resp))
+//		}
+//		if resp.StatusCode == http.StatusBadRequest {
+//			return nil, async.Permanent(errBadRequest)
+//		}
+//		return resp.Body, nil
+//	})
+func RetryCtx[T any](ctx context.Context, policy RetryPolicy, f func(context.Context) (T, error), opts ...Option) (T, error) {
+	var zero T
+	clock := applyOptions(opts).clock
+	start := clock.Now()
+	backoff := newBackoff(policy)
+
+	for attempt := 1; ; attempt++ {
+		result, err := f(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			if err == perm {
+				return zero, perm.err // strip the marker
+			}
+			return zero, err // keep the caller's wrapping; perm unwraps to the cause
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return zero, err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return zero, fmt.Errorf("after %d attempts, last error: %w", attempt, err)
+		}
+
+		delay := backoff.next()
+		var ra interface{ RetryAfter() time.Duration }
+		if errors.As(err, &ra) {
+			delay = ra.RetryAfter()
+		}
+		if policy.MaxElapsed > 0 && clock.Now().Sub(start)+delay > policy.MaxElapsed {
+			return zero, fmt.Errorf("after %d attempts, last error: %w", attempt, err)
+		}
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		if cerr := sleepCtx(ctx, clock, delay); cerr != nil {
+			return zero, fmt.Errorf("after %d attempts: %w (last error: %w)", attempt, cerr, err)
+		}
+	}
+}
+
+// Permanent wraps err so that RetryCtx stops retrying and returns err immediately.
+// The mark may itself be wrapped, as in fmt.Errorf("load %s: %w", id, Permanent(err)).
+//
+// Example:
+//
+//	if resp.StatusCode == http.StatusNotFound {
+//		return nil, async.Permanent(ErrNotFound)
+//	}
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// RetryAfter wraps err so that RetryCtx waits d before the next attempt instead of
+// the delay computed by its policy, for example to honour an HTTP Retry-After header.
+// Any error with a RetryAfter() time.Duration method has the same effect.
+//
+// Example:
+//
+//	return nil, async.RetryAfter(errThrottled, 30*time.Second)
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, delay: d}
+}
+
+// permanentError marks an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// retryAfterError carries the delay requested before the next attempt.
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+func (e *retryAfterError) RetryAfter() time.Duration { return e.delay }
+
+// backoff computes the successive delays of a RetryPolicy.
+type backoff struct {
+	policy     RetryPolicy
+	multiplier float64
+	retries    int
+	prev       time.Duration
+}
+
+// newBackoff returns the delay generator for policy.
+func newBackoff(policy RetryPolicy) *backoff {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	return &backoff{policy: policy, multiplier: multiplier, prev: policy.InitialDelay}
+}
+
+// next returns the delay before the next retry.
+func (b *backoff) next() time.Duration {
+	p := b.policy
+	exp := float64(p.InitialDelay) * math.Pow(b.multiplier, float64(b.retries))
+	b.retries++
+
+	base := b.capped(exp)
+	switch p.Jitter {
+	case FullJitter:
+		return randDuration(0, base)
+	case EqualJitter:
+		return base/2 + randDuration(0, base-base/2)
+	case DecorrelatedJitter:
+		d := b.capped(float64(randDuration(p.InitialDelay, 3*b.prev)))
+		b.prev = d
+		return d
+	default:
+		return base
+	}
+}
+
+// capped converts d to a Duration, limiting it to MaxDelay and to the largest
+// representable Duration.
+func (b *backoff) capped(d float64) time.Duration {
+	if b.policy.MaxDelay > 0 && d > float64(b.policy.MaxDelay) {
+		return b.policy.MaxDelay
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// randDuration returns a random duration in [lo, hi].
+func randDuration(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)+1))
+}
+
+// sleepCtx waits for d on clock or until ctx is done, returning ctx.Err() in the latter case.
+func sleepCtx(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
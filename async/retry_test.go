@@ -0,0 +1,274 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("succeeds on first attempt", func(t *testing.T) {
+		attempts := 0
+		result, err := Retry(func() (string, error) {
+			attempts++
+			return "success", nil
+		}, 3, 10*time.Millisecond)
+
+		if err != nil {
+			t.Errorf("Retry() should not error on success, got: %v", err)
+		}
+		if result != "success" {
+			t.Errorf("Retry() result = %v, want 'success'", result)
+		}
+		if attempts != 1 {
+			t.Errorf("Retry() attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("succeeds on third attempt", func(t *testing.T) {
+		attempts := 0
+		result, err := Retry(func() (string, error) {
+			attempts++
+			if attempts < 3 {
+				return "", fmt.Errorf("attempt %d failed", attempts)
+			}
+			return "success", nil
+		}, 3, 10*time.Millisecond)
+
+		if err != nil {
+			t.Errorf("Retry() should not error on eventual success, got: %v", err)
+		}
+		if result != "success" {
+			t.Errorf("Retry() result = %v, want 'success'", result)
+		}
+		if attempts != 3 {
+			t.Errorf("Retry() attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("fails after all attempts", func(t *testing.T) {
+		attempts := 0
+		result, err := Retry(func() (string, error) {
+			attempts++
+			return "", fmt.Errorf("attempt %d failed", attempts)
+		}, 3, 10*time.Millisecond)
+
+		if err == nil {
+			t.Error("Retry() should error after all attempts fail")
+		}
+		if result != "" {
+			t.Errorf("Retry() result = %v, want empty string", result)
+		}
+		if attempts != 3 {
+			t.Errorf("Retry() attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("zero attempts defaults to one", func(t *testing.T) {
+		attempts := 0
+		_, err := Retry(func() (string, error) {
+			attempts++
+			return "", fmt.Errorf("failed")
+		}, 0, 10*time.Millisecond)
+
+		if err == nil {
+			t.Error("Retry() should error when function fails")
+		}
+		if attempts != 1 {
+			t.Errorf("Retry() attempts = %d, want 1", attempts)
+		}
+	})
+}
+
+func TestRetryCtx(t *testing.T) {
+	t.Run("waits on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Hour}
+		errFail := errors.New("fail")
+		attempts := 0
+		errc := make(chan error, 1)
+		go func() {
+			_, err := RetryCtx(context.Background(), policy, func(context.Context) (int, error) {
+				attempts++
+				return 0, errFail
+			}, WithClock(clock))
+			errc <- err
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+		clock.BlockUntil(1)
+		clock.Advance(2 * time.Hour)
+		if err := <-errc; !errors.Is(err, errFail) || attempts != 3 {
+			t.Errorf("RetryCtx() = %v after %d attempts, want %v after 3", err, attempts, errFail)
+		}
+	})
+
+	t.Run("grows delays exponentially", func(t *testing.T) {
+		var delays []time.Duration
+		policy := RetryPolicy{
+			MaxAttempts:  5,
+			InitialDelay: time.Millisecond,
+			OnRetry: func(_ int, _ error, d time.Duration) {
+				delays = append(delays, d)
+			},
+		}
+
+		_, err := RetryCtx(context.Background(), policy, func(context.Context) (int, error) {
+			return 0, errors.New("fail")
+		})
+
+		if err == nil {
+			t.Fatal("RetryCtx() should fail after all attempts")
+		}
+		want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond}
+		if fmt.Sprint(delays) != fmt.Sprint(want) {
+			t.Errorf("delays = %v, want %v", delays, want)
+		}
+	})
+
+	t.Run("caps delays", func(t *testing.T) {
+		b := newBackoff(RetryPolicy{InitialDelay: time.Second, MaxDelay: 3 * time.Second, Multiplier: 10})
+		got := []time.Duration{b.next(), b.next(), b.next()}
+		want := []time.Duration{time.Second, 3 * time.Second, 3 * time.Second}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("delays = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("jitter stays within bounds", func(t *testing.T) {
+		for _, jitter := range []Jitter{FullJitter, EqualJitter, DecorrelatedJitter} {
+			b := newBackoff(RetryPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 80 * time.Millisecond, Jitter: jitter})
+			for i := 0; i < 10; i++ {
+				base := 10 * time.Millisecond << uint(i)
+				if base > 80*time.Millisecond {
+					base = 80 * time.Millisecond
+				}
+				d := b.next()
+				lo := time.Duration(0)
+				switch jitter {
+				case EqualJitter:
+					lo = base / 2
+				case DecorrelatedJitter:
+					lo, base = 10*time.Millisecond, 80*time.Millisecond
+				}
+				if d < lo || d > base {
+					t.Fatalf("jitter %d: delay %d = %v, want within [%v, %v]", jitter, i, d, lo, base)
+				}
+			}
+		}
+	})
+
+	t.Run("stops on permanent error", func(t *testing.T) {
+		errFatal := errors.New("fatal")
+		attempts := 0
+		_, err := RetryCtx(context.Background(), RetryPolicy{MaxAttempts: 5}, func(context.Context) (int, error) {
+			attempts++
+			return 0, Permanent(errFatal)
+		})
+
+		if err != errFatal {
+			t.Errorf("RetryCtx() error = %v, want %v", err, errFatal)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("keeps the wrapping around a permanent error", func(t *testing.T) {
+		errFatal := errors.New("fatal")
+		attempts := 0
+		_, err := RetryCtx(context.Background(), RetryPolicy{MaxAttempts: 5}, func(context.Context) (int, error) {
+			attempts++
+			return 0, fmt.Errorf("load user 42: %w", Permanent(errFatal))
+		})
+
+		if !errors.Is(err, errFatal) || err.Error() != "load user 42: fatal" {
+			t.Errorf("RetryCtx() error = %v, want the wrapped fatal error", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	})
+
+	t.Run("stops on non-retryable error", func(t *testing.T) {
+		errNotFound := errors.New("not found")
+		attempts := 0
+		policy := RetryPolicy{
+			MaxAttempts: 5,
+			Retryable:   func(err error) bool { return !errors.Is(err, errNotFound) },
+		}
+		_, err := RetryCtx(context.Background(), policy, func(context.Context) (int, error) {
+			attempts++
+			if attempts == 2 {
+				return 0, errNotFound
+			}
+			return 0, errors.New("transient")
+		})
+
+		if err != errNotFound || attempts != 2 {
+			t.Errorf("RetryCtx() = %v after %d attempts, want %v after 2", err, attempts, errNotFound)
+		}
+	})
+
+	t.Run("honours retry-after", func(t *testing.T) {
+		var delays []time.Duration
+		policy := RetryPolicy{
+			MaxAttempts:  2,
+			InitialDelay: time.Hour,
+			OnRetry:      func(_ int, _ error, d time.Duration) { delays = append(delays, d) },
+		}
+		attempts := 0
+		_, err := RetryCtx(context.Background(), policy, func(context.Context) (int, error) {
+			attempts++
+			if attempts == 1 {
+				return 0, RetryAfter(errors.New("throttled"), time.Millisecond)
+			}
+			return 1, nil
+		})
+
+		if err != nil {
+			t.Fatalf("RetryCtx() error = %v", err)
+		}
+		if len(delays) != 1 || delays[0] != time.Millisecond {
+			t.Errorf("delays = %v, want [1ms]", delays)
+		}
+	})
+
+	t.Run("respects max elapsed", func(t *testing.T) {
+		errFail := errors.New("fail")
+		attempts := 0
+		policy := RetryPolicy{InitialDelay: 10 * time.Millisecond, MaxElapsed: 25 * time.Millisecond}
+		_, err := RetryCtx(context.Background(), policy, func(context.Context) (int, error) {
+			attempts++
+			return 0, errFail
+		})
+
+		if !errors.Is(err, errFail) {
+			t.Errorf("RetryCtx() error = %v, want it to wrap %v", err, errFail)
+		}
+		if attempts != 2 {
+			t.Errorf("attempts = %d, want 2", attempts)
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		errFail := errors.New("fail")
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := RetryCtx(ctx, RetryPolicy{InitialDelay: time.Hour}, func(context.Context) (int, error) {
+			return 0, errFail
+		})
+
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errFail) {
+			t.Errorf("RetryCtx() error = %v, want it to wrap DeadlineExceeded and %v", err, errFail)
+		}
+		if time.Since(start) > time.Second {
+			t.Error("RetryCtx() kept sleeping after the context was cancelled")
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerStopped is returned when adding a job to a stopped Scheduler.
+var ErrSchedulerStopped = errors.New("async: scheduler is stopped")
+
+// JobRun describes one run of a scheduled job, as reported to the hook set
+// with WithRunHook.
+type JobRun struct {
+	// Name is the name the job was added with.
+	Name string
+	// Scheduled is the activation time computed by the job's Schedule, before jitter.
+	Scheduled time.Time
+	// Start is the time the run started.
+	Start time.Time
+	// Duration is the time the run took.
+	Duration time.Duration
+	// Err is the error returned by the job, or a *PanicError if it panicked.
+	Err error
+}
+
+// Scheduler runs jobs on interval or cron schedules. A job never overlaps with
+// itself: activations that fall while its previous run is still going are
+// skipped. It is safe for concurrent use.
+//
+// Scheduler accepts WithJitter to spread runs that share a schedule,
+// WithRunHook to observe the outcome of every run, WithPanicHandler, and
+// WithClock to run the jobs on another clock.
+//
+// Example:
+//
+//	scheduler := async.NewScheduler(
+//		async.WithJitter(5*time.Second),
+//		async.WithRunHook(func(run async.JobRun) {
+//			if run.Err != nil {
+//				log.Printf("job %s failed: %v", run.Name, run.Err)
+//			}
+//		}),
+//	)
+//	scheduler.Add("purge-sessions", async.Every(time.Minute), purgeSessions)
+//	scheduler.AddCron("nightly-report", "CRON_TZ=Europe/Paris 0 3 * * *", buildReport)
+//
+//	<-shutdown
+//	scheduler.Stop(ctx) // waits for running jobs until ctx is done
+type Scheduler struct {
+	jitter       time.Duration
+	runHook      func(JobRun)
+	panicHandler func(*PanicError)
+	clock        Clock
+
+	// ctx stops the job loops; runCtx is passed to jobs and is only cancelled
+	// when Stop gives up waiting for them.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]context.CancelFunc
+	stopped bool
+}
+
+// NewScheduler returns a Scheduler without jobs.
+func NewScheduler(opts ...Option) *Scheduler {
+	o := applyOptions(opts)
+	s := &Scheduler{
+		jitter:       o.jitter,
+		runHook:      o.runHook,
+		panicHandler: o.panicHandler,
+		clock:        o.clock,
+		jobs:         make(map[string]context.CancelFunc),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.runCtx, s.runCancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Add schedules job under a unique name. Its first run is at the first
+// activation of schedule after now.
+func (s *Scheduler) Add(name string, schedule Schedule, job func(context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("async: job %q is already scheduled", name)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.jobs[name] = cancel
+	s.wg.Add(1)
+	go s.loop(ctx, name, schedule, job)
+	return nil
+}
+
+// AddCron schedules job with a cron expression, see ParseCron.
+func (s *Scheduler) AddCron(name, expr string, job func(context.Context) error) error {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	return s.Add(name, schedule, job)
+}
+
+// Remove unschedules the job with the given name and reports whether it existed.
+// A run in progress is not interrupted.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cancel, ok := s.jobs[name]
+	if ok {
+		cancel()
+		delete(s.jobs, name)
+	}
+	return ok
+}
+
+// Stop stops scheduling runs and waits for the runs in progress to finish.
+// If ctx is done first, it cancels the context passed to the running jobs and
+// returns ctx.Err() without waiting further.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	s.jobs = make(map[string]context.CancelFunc)
+	s.mu.Unlock()
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		s.runCancel()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.runCancel()
+		return ctx.Err()
+	}
+}
+
+// loop runs one job at each activation of its schedule until ctx is done.
+func (s *Scheduler) loop(ctx context.Context, name string, schedule Schedule, job func(context.Context) error) {
+	defer s.wg.Done()
+
+	next := schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		delay := next.Sub(s.clock.Now())
+		if s.jitter > 0 {
+			delay += randDuration(0, s.jitter)
+		}
+		// Check ctx again: a timer that fired as the job was removed must not run it.
+		if err := sleepCtx(ctx, s.clock, delay); err != nil || ctx.Err() != nil {
+			return
+		}
+
+		s.run(name, next, job)
+
+		// Skip the activations missed while the job was running.
+		now := s.clock.Now()
+		if next = schedule.Next(next); !next.IsZero() && next.Before(now) {
+			next = schedule.Next(now)
+		}
+	}
+}
+
+// run runs the job once and reports the outcome to the run hook.
+func (s *Scheduler) run(name string, scheduled time.Time, job func(context.Context) error) {
+	start := s.clock.Now()
+	err := catch(func() error {
+		return job(s.runCtx)
+	}, s.panicHandler)
+
+	if s.runHook != nil {
+		s.runHook(JobRun{
+			Name:      name,
+			Scheduled: scheduled,
+			Start:     start,
+			Duration:  s.clock.Now().Sub(start),
+			Err:       err,
+		})
+	}
+}
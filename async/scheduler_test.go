@@ -0,0 +1,209 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("runs on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		runs := make(chan JobRun, 1)
+		s := NewScheduler(WithClock(clock), WithRunHook(func(run JobRun) { runs <- run }))
+		defer s.Stop(context.Background())
+
+		start := clock.Now()
+		if err := s.Add("job", Every(time.Minute), func(context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 3; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+			run := <-runs
+			if want := start.Add(time.Duration(i) * time.Minute); !run.Scheduled.Equal(want) {
+				t.Errorf("run %d scheduled at %v, want %v", i, run.Scheduled, want)
+			}
+		}
+	})
+
+	t.Run("runs jobs and reports outcomes", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		errJob := errors.New("job failed")
+		runs := make(chan JobRun, 2)
+		s := NewScheduler(WithClock(clock), WithRunHook(func(run JobRun) { runs <- run }))
+		s.Add("ok", Every(time.Minute), func(context.Context) error { return nil })
+		s.Add("failing", Every(time.Minute), func(context.Context) error { return errJob })
+
+		counts := map[string]int{}
+		failures := 0
+		for i := 0; i < 3; i++ {
+			clock.BlockUntil(2)
+			clock.Advance(time.Minute)
+			for j := 0; j < 2; j++ {
+				run := <-runs
+				counts[run.Name]++
+				if errors.Is(run.Err, errJob) {
+					failures++
+				}
+			}
+		}
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatalf("Stop() = %v", err)
+		}
+
+		if counts["ok"] != 3 || counts["failing"] != 3 {
+			t.Errorf("runs = %v, want 3 of each", counts)
+		}
+		if failures != 3 {
+			t.Errorf("reported %d failures, want 3", failures)
+		}
+	})
+
+	t.Run("prevents overlapping runs", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		runs := make(chan JobRun, 1)
+		s := NewScheduler(WithClock(clock), WithRunHook(func(run JobRun) { runs <- run }))
+		defer s.Stop(context.Background())
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		start := clock.Now()
+		s.Add("slow", Every(time.Minute), func(context.Context) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-started
+		// The job is still running, so the activations at 2, 3 and 4 minutes are skipped.
+		clock.Advance(3 * time.Minute)
+		select {
+		case <-started:
+			t.Fatal("a run started while the previous one was running")
+		default:
+		}
+		release <- struct{}{}
+		<-runs
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-started
+		release <- struct{}{}
+		if run := <-runs; !run.Scheduled.Equal(start.Add(5 * time.Minute)) {
+			t.Errorf("next run scheduled at %v, want %v", run.Scheduled, start.Add(5*time.Minute))
+		}
+	})
+
+	t.Run("Stop waits for running jobs", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		s := NewScheduler(WithClock(clock))
+		started := make(chan struct{})
+		var finished int32
+		s.Add("job", Every(time.Minute), func(context.Context) error {
+			close(started)
+			clock.Sleep(time.Minute)
+			atomic.StoreInt32(&finished, 1)
+			return nil
+		})
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-started
+		stopped := make(chan error, 1)
+		go func() { stopped <- s.Stop(context.Background()) }()
+		clock.BlockUntil(1) // the job is sleeping
+		select {
+		case <-stopped:
+			t.Fatal("Stop() returned before the running job finished")
+		default:
+		}
+
+		clock.Advance(time.Minute)
+		if err := <-stopped; err != nil {
+			t.Fatalf("Stop() = %v", err)
+		}
+		if atomic.LoadInt32(&finished) != 1 {
+			t.Error("Stop() returned before the running job finished")
+		}
+		if err := s.Add("late", Every(time.Second), nil); !errors.Is(err, ErrSchedulerStopped) {
+			t.Errorf("Add() after Stop = %v, want ErrSchedulerStopped", err)
+		}
+	})
+
+	t.Run("Stop cancels jobs when its context expires", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		s := NewScheduler(WithClock(clock))
+		started := make(chan struct{})
+		cancelled := make(chan struct{})
+		s.Add("stuck", Every(time.Minute), func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		})
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-started
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := s.Stop(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Stop() = %v, want context.Canceled", err)
+		}
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("job context was not cancelled")
+		}
+	})
+
+	t.Run("Add and Remove", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		runs := make(chan JobRun, 10)
+		s := NewScheduler(WithClock(clock), WithRunHook(func(run JobRun) { runs <- run }))
+
+		job := func(context.Context) error { return nil }
+		if err := s.Add("job", Every(time.Minute), job); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add("job", Every(time.Second), job); err == nil {
+			t.Error("Add() with a duplicate name = nil, want error")
+		}
+		if err := s.AddCron("bad", "not a cron", job); err == nil {
+			t.Error("AddCron() with an invalid expression = nil, want error")
+		}
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-runs
+		clock.BlockUntil(1)
+		if !s.Remove("job") {
+			t.Fatal("Remove() = false, want true")
+		}
+		clock.Advance(time.Hour)
+		s.Stop(context.Background()) // waits for the removed job's loop to exit
+		if n := len(runs); n != 0 {
+			t.Errorf("job ran %d times after Remove", n)
+		}
+		if s.Remove("job") {
+			t.Error("second Remove() = true, want false")
+		}
+	})
+
+	t.Run("panics are reported", func(t *testing.T) {
+		reported := make(chan error, 100)
+		s := NewScheduler(WithRunHook(func(run JobRun) { reported <- run.Err }))
+		defer s.Stop(context.Background())
+
+		s.Add("panics", Every(5*time.Millisecond), func(context.Context) error { panic("boom") })
+		var pe *PanicError
+		if err := <-reported; !errors.As(err, &pe) {
+			t.Errorf("run error = %v, want *PanicError", err)
+		}
+	})
+}
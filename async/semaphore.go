@@ -0,0 +1,131 @@
+package async
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Semaphore is a weighted semaphore: it bounds the total weight of the work
+// running at once rather than the number of goroutines, for example the bytes
+// held in memory or the connections used. Waiters are served in FIFO order, so
+// a large request is not starved by a stream of small ones. It is safe for
+// concurrent use.
+//
+// Example:
+//
+//	mem := async.NewSemaphore(512 << 20) // 512MB of buffers
+//	for _, f := range files {
+//		if err := mem.Acquire(ctx, f.Size); err != nil {
+//			return err
+//		}
+//		go func(f File) {
+//			defer mem.Release(f.Size)
+//			process(f)
+//		}(f)
+//	}
+type Semaphore struct {
+	size int64
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of *semaphoreWaiter
+}
+
+// semaphoreWaiter is a blocked Acquire call.
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// NewSemaphore returns a Semaphore with the given total weight.
+func NewSemaphore(size int64) *Semaphore {
+	return &Semaphore{size: size}
+}
+
+// Acquire blocks until a weight of n is available or ctx is done, in which case
+// it returns ctx.Err() and acquires nothing. It fails right away if n exceeds the
+// semaphore's size, since such a request could never be granted.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if n > s.size {
+		s.mu.Unlock()
+		return fmt.Errorf("async: semaphore acquire of %d exceeds size %d", n, s.size)
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Granted while ctx was being cancelled: give the weight back.
+		s.cur -= n
+		s.notify()
+	default:
+		front := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		// Removing the head may let the waiters behind it through.
+		if front {
+			s.notify()
+		}
+	}
+	return ctx.Err()
+}
+
+// TryAcquire acquires a weight of n if it is available right away, without
+// blocking, and reports whether it did.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release releases a weight of n. It panics if more weight is released than is held.
+func (s *Semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	if s.cur < 0 {
+		s.cur += n
+		panic("async: semaphore released more than held")
+	}
+	s.notify()
+}
+
+// notify wakes the waiters at the head of the queue whose weight fits. s.mu must be held.
+func (s *Semaphore) notify() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,133 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	t.Run("bounds the acquired weight", func(t *testing.T) {
+		s := NewSemaphore(10)
+		var held, peak int64
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			n := int64(i%4 + 1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.Acquire(context.Background(), n); err != nil {
+					t.Errorf("Acquire(%d) = %v", n, err)
+					return
+				}
+				cur := atomic.AddInt64(&held, n)
+				for {
+					p := atomic.LoadInt64(&peak)
+					if cur <= p || atomic.CompareAndSwapInt64(&peak, p, cur) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&held, -n)
+				s.Release(n)
+			}()
+		}
+		wg.Wait()
+
+		if peak > 10 {
+			t.Errorf("peak weight = %d, want at most 10", peak)
+		}
+	})
+
+	t.Run("TryAcquire", func(t *testing.T) {
+		s := NewSemaphore(3)
+		if !s.TryAcquire(2) {
+			t.Fatal("TryAcquire(2) = false, want true")
+		}
+		if s.TryAcquire(2) {
+			t.Error("TryAcquire(2) over the size = true, want false")
+		}
+		s.Release(2)
+		if !s.TryAcquire(3) {
+			t.Error("TryAcquire(3) after Release = false, want true")
+		}
+	})
+
+	t.Run("waiters are served in order", func(t *testing.T) {
+		s := NewSemaphore(2)
+		s.Acquire(context.Background(), 2)
+
+		big := make(chan struct{})
+		go func() {
+			s.Acquire(context.Background(), 2)
+			close(big)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		if s.TryAcquire(1) {
+			t.Error("TryAcquire(1) ahead of a waiter = true, want false")
+		}
+		s.Release(2)
+		select {
+		case <-big:
+		case <-time.After(time.Second):
+			t.Fatal("waiter was not woken")
+		}
+	})
+
+	t.Run("Acquire honours the context", func(t *testing.T) {
+		s := NewSemaphore(1)
+		s.Acquire(context.Background(), 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := s.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Acquire() = %v, want context.DeadlineExceeded", err)
+		}
+
+		s.Release(1)
+		if !s.TryAcquire(1) {
+			t.Error("TryAcquire() after a cancelled waiter = false, want true")
+		}
+	})
+
+	t.Run("cancelled head lets smaller waiters through", func(t *testing.T) {
+		s := NewSemaphore(2)
+		s.Acquire(context.Background(), 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go s.Acquire(ctx, 2)
+		time.Sleep(10 * time.Millisecond)
+
+		small := make(chan error, 1)
+		go func() { small <- s.Acquire(context.Background(), 1) }()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-small:
+			if err != nil {
+				t.Errorf("Acquire(1) = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("waiter behind a cancelled one was not woken")
+		}
+	})
+
+	t.Run("errors and panics", func(t *testing.T) {
+		s := NewSemaphore(1)
+		if err := s.Acquire(context.Background(), 2); err == nil {
+			t.Error("Acquire() over the size = nil, want error")
+		}
+
+		defer func() {
+			if recover() == nil {
+				t.Error("Release() of unheld weight did not panic")
+			}
+		}()
+		s.Release(1)
+	})
+}
@@ -0,0 +1,139 @@
+package async
+
+import (
+	"context"
+	"sync"
+)
+
+// Group collapses concurrent calls for the same key into a single execution
+// whose result and error are shared by every caller, which keeps a burst of
+// cache misses from all hitting the backing store at once. The zero value is
+// ready to use and a Group must not be copied after first use.
+//
+// Example:
+//
+//	var users async.Group[string, User]
+//
+//	func getUser(id string) (User, error) {
+//		if u, ok := cache.Get(id); ok {
+//			return u, nil
+//		}
+//		u, err, _ := users.Do(id, func() (User, error) {
+//			return db.LoadUser(id)
+//		})
+//		return u, err
+//	}
+type Group[K comparable, V any] struct {
+	mu      sync.Mutex
+	flights map[K]*flight[V]
+}
+
+// flight is an execution in progress, or completed, for one key of a Group.
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+	dups  int
+}
+
+// Do calls fn and returns its result, unless a call for the same key is already
+// in flight, in which case it waits for that call and returns its result instead.
+// shared reports whether the result was given to more than one caller.
+// A panic in fn is recovered and returned to every caller as a *PanicError.
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	f, started := g.join(key)
+	if started {
+		g.run(key, f, fn)
+	} else {
+		<-f.done
+	}
+
+	g.mu.Lock()
+	shared = f.dups > 0
+	g.mu.Unlock()
+	return f.value, f.err, shared
+}
+
+// DoChan is like Do but runs the call on its own goroutine and returns a channel
+// that receives its result. If ctx is done first, the channel receives ctx.Err()
+// instead; the shared call itself keeps running for the other callers, and its
+// result is still stored for them.
+//
+// Example:
+//
+//	select {
+//	case r := <-group.DoChan(ctx, key, load):
+//		return r.Value, r.Err
+//	case <-shutdown:
+//		return zero, errShuttingDown
+//	}
+func (g *Group[K, V]) DoChan(ctx context.Context, key K, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	f, started := g.join(key)
+	if started {
+		go g.run(key, f, fn)
+	}
+
+	go func() {
+		select {
+		case <-f.done:
+			ch <- Result[V]{Value: f.value, Err: f.err}
+		case <-ctx.Done():
+			select {
+			case <-f.done:
+				ch <- Result[V]{Value: f.value, Err: f.err}
+			default:
+				ch <- Result[V]{Err: ctx.Err()}
+			}
+		}
+	}()
+	return ch
+}
+
+// Forget makes the next call for key start a new execution even if one is
+// still in flight. Callers already waiting for the current one still get its result.
+//
+// Example:
+//
+//	// The user was just updated: do not hand out the result of a load that
+//	// started before the update.
+//	users.Forget(id)
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+}
+
+// join returns the flight for key, starting a new one if none is in progress.
+// started reports whether the caller must run the new flight.
+func (g *Group[K, V]) join(key K) (f *flight[V], started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		f.dups++
+		return f, false
+	}
+	if g.flights == nil {
+		g.flights = make(map[K]*flight[V])
+	}
+	f = &flight[V]{done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true
+}
+
+// run executes fn for the flight, publishes its result and removes the flight
+// unless it has been forgotten and replaced.
+func (g *Group[K, V]) run(key K, f *flight[V], fn func() (V, error)) {
+	f.err = catch(func() (err error) {
+		f.value, err = fn()
+		return err
+	}, nil)
+
+	g.mu.Lock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	g.mu.Unlock()
+	close(f.done)
+}
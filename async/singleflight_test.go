@@ -0,0 +1,122 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("collapses concurrent calls", func(t *testing.T) {
+		var g Group[string, int]
+		var calls int32
+		release := make(chan struct{})
+
+		const n = 10
+		var wg sync.WaitGroup
+		var shared int32
+		results := make([]int, n)
+		for i := 0; i < n; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err, s := g.Do("key", func() (int, error) {
+					atomic.AddInt32(&calls, 1)
+					<-release
+					return 42, nil
+				})
+				if err != nil {
+					t.Errorf("Do() error = %v", err)
+				}
+				if s {
+					atomic.AddInt32(&shared, 1)
+				}
+				results[i] = v
+			}()
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls != 1 {
+			t.Errorf("fn called %d times, want 1", calls)
+		}
+		if shared != n {
+			t.Errorf("%d callers reported a shared result, want %d", shared, n)
+		}
+		for _, v := range results {
+			if v != 42 {
+				t.Errorf("Do() = %d, want 42", v)
+			}
+		}
+	})
+
+	t.Run("shares errors and runs again afterwards", func(t *testing.T) {
+		var g Group[int, string]
+		want := errors.New("failed")
+		if _, err, shared := g.Do(1, func() (string, error) { return "", want }); err != want || shared {
+			t.Errorf("Do() = %v, shared %v, want %v, not shared", err, shared, want)
+		}
+		if v, err, _ := g.Do(1, func() (string, error) { return "ok", nil }); err != nil || v != "ok" {
+			t.Errorf("second Do() = %q, %v, want \"ok\", nil", v, err)
+		}
+	})
+
+	t.Run("panics become errors", func(t *testing.T) {
+		var g Group[int, int]
+		_, err, _ := g.Do(1, func() (int, error) { panic("boom") })
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Errorf("Do() = %v, want *PanicError", err)
+		}
+	})
+
+	t.Run("Forget starts a new call", func(t *testing.T) {
+		var g Group[string, int]
+		release := make(chan struct{})
+		first := g.DoChan(context.Background(), "key", func() (int, error) {
+			<-release
+			return 1, nil
+		})
+		time.Sleep(10 * time.Millisecond)
+
+		g.Forget("key")
+		v, _, _ := g.Do("key", func() (int, error) { return 2, nil })
+		if v != 2 {
+			t.Errorf("Do() after Forget = %d, want 2", v)
+		}
+
+		close(release)
+		if r := <-first; r.Value != 1 {
+			t.Errorf("forgotten call = %d, want 1", r.Value)
+		}
+	})
+
+	t.Run("DoChan waiter gives up without cancelling the call", func(t *testing.T) {
+		var g Group[string, int]
+		release := make(chan struct{})
+		fn := func() (int, error) {
+			<-release
+			return 7, nil
+		}
+
+		patient := g.DoChan(context.Background(), "key", fn)
+		ctx, cancel := context.WithCancel(context.Background())
+		impatient := g.DoChan(ctx, "key", fn)
+		cancel()
+
+		if r := <-impatient; !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("cancelled DoChan() = %v, want context.Canceled", r.Err)
+		}
+
+		close(release)
+		if r := <-patient; r.Err != nil || r.Value != 7 {
+			t.Errorf("DoChan() = %d, %v, want 7, nil", r.Value, r.Err)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package async
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Hooks are callbacks invoked over the life cycle of each task run by a Pool or
+// an ErrGroup. Any of them may be nil. Hooks run synchronously on the submitting
+// goroutine (OnSubmit) or on the goroutine executing the task (OnStart, OnFinish),
+// so they should return quickly.
+//
+// Example:
+//
+//	pool := async.NewPool(4, async.WithHooks(async.Hooks{
+//		OnFinish: func(ev async.TaskEvent) {
+//			taskLatency.Observe(ev.Run.Seconds())
+//		},
+//	}))
+type Hooks struct {
+	// OnSubmit is called once a task has been accepted.
+	OnSubmit func(TaskEvent)
+	// OnStart is called right before a task starts executing.
+	OnStart func(TaskEvent)
+	// OnFinish is called after a task has returned or panicked.
+	OnFinish func(TaskEvent)
+}
+
+// TaskEvent describes a task at one point of its life cycle.
+type TaskEvent struct {
+	// ID identifies the task within its Pool or ErrGroup, so that the events of
+	// a single task can be correlated, for example to start and end a trace span.
+	ID uint64
+	// Wait is the time the task spent queued before it started.
+	// It is zero in OnSubmit events.
+	Wait time.Duration
+	// Run is the time the task spent executing. It is only set in OnFinish events.
+	Run time.Duration
+	// Err is the task's failure, if any, in OnFinish events: the error returned
+	// by an ErrGroup function, or a *PanicError for a panicking task.
+	Err error
+}
+
+// submit calls the OnSubmit hook if it is set.
+func (h Hooks) submit(ev TaskEvent) {
+	if h.OnSubmit != nil {
+		h.OnSubmit(ev)
+	}
+}
+
+// start calls the OnStart hook if it is set.
+func (h Hooks) start(ev TaskEvent) {
+	if h.OnStart != nil {
+		h.OnStart(ev)
+	}
+}
+
+// finish calls the OnFinish hook if it is set.
+func (h Hooks) finish(ev TaskEvent) {
+	if h.OnFinish != nil {
+		h.OnFinish(ev)
+	}
+}
+
+// defaultBuckets are the upper bounds of the latency histograms kept by Pool.
+var defaultBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a snapshot of a latency distribution.
+// Counts[i] is the number of observations less than or equal to Bounds[i] and
+// greater than the previous bound; the final element of Counts counts the
+// observations above the last bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// Mean returns the average observed duration, or zero if nothing was observed.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) of the observed durations by
+// returning the upper bound of the bucket that contains it. Observations above
+// the last bound are reported as the maximum observed duration.
+//
+// Example:
+//
+//	p99 := pool.Stats().RunTime.Quantile(0.99)
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank {
+			if i < len(h.Bounds) {
+				return h.Bounds[i]
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// histogram records durations into the default buckets. It is safe for concurrent use.
+type histogram struct {
+	mu     sync.Mutex
+	counts [len(defaultBuckets) + 1]uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// observe records a single duration.
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(defaultBuckets) && d > defaultBuckets[i] {
+		i++
+	}
+
+	h.mu.Lock()
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+	h.mu.Unlock()
+}
+
+// snapshot returns a copy of the recorded distribution.
+func (h *histogram) snapshot() Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Histogram{
+		Bounds: append([]time.Duration(nil), defaultBuckets[:]...),
+		Counts: append([]uint64(nil), h.counts[:]...),
+		Count:  h.count,
+		Sum:    h.sum,
+		Max:    h.max,
+	}
+}
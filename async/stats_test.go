@@ -0,0 +1,70 @@
+package async
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Run("buckets observations", func(t *testing.T) {
+		var h histogram
+		h.observe(50 * time.Microsecond)
+		h.observe(3 * time.Millisecond)
+		h.observe(3 * time.Millisecond)
+		h.observe(time.Minute)
+
+		snap := h.snapshot()
+		if snap.Count != 4 {
+			t.Errorf("Count = %d, want 4", snap.Count)
+		}
+		if snap.Counts[0] != 1 {
+			t.Errorf("Counts[0] = %d, want 1", snap.Counts[0])
+		}
+		if snap.Counts[len(snap.Counts)-1] != 1 {
+			t.Errorf("overflow bucket = %d, want 1", snap.Counts[len(snap.Counts)-1])
+		}
+		if snap.Max != time.Minute {
+			t.Errorf("Max = %v, want 1m", snap.Max)
+		}
+		if len(snap.Counts) != len(snap.Bounds)+1 {
+			t.Errorf("len(Counts) = %d, want len(Bounds)+1 = %d", len(snap.Counts), len(snap.Bounds)+1)
+		}
+	})
+
+	t.Run("mean and quantiles", func(t *testing.T) {
+		var h histogram
+		for i := 0; i < 9; i++ {
+			h.observe(time.Millisecond)
+		}
+		h.observe(20 * time.Millisecond)
+
+		snap := h.snapshot()
+		if got, want := snap.Mean(), 2900*time.Microsecond; got != want {
+			t.Errorf("Mean() = %v, want %v", got, want)
+		}
+		if got := snap.Quantile(0.5); got != time.Millisecond {
+			t.Errorf("Quantile(0.5) = %v, want 1ms", got)
+		}
+		if got := snap.Quantile(0.99); got != 25*time.Millisecond {
+			t.Errorf("Quantile(0.99) = %v, want 25ms", got)
+		}
+		if got := snap.Quantile(2); got != 25*time.Millisecond {
+			t.Errorf("Quantile(2) = %v, want 25ms", got)
+		}
+	})
+
+	t.Run("overflow quantile reports max", func(t *testing.T) {
+		var h histogram
+		h.observe(time.Hour)
+		if got := h.snapshot().Quantile(1); got != time.Hour {
+			t.Errorf("Quantile(1) = %v, want 1h", got)
+		}
+	})
+
+	t.Run("empty histogram", func(t *testing.T) {
+		var h Histogram
+		if h.Mean() != 0 || h.Quantile(0.5) != 0 {
+			t.Error("empty Histogram should report zero mean and quantiles")
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTooManyRestarts is returned by Supervisor.Run when services fail more often
+// than the configured restart intensity allows.
+var ErrTooManyRestarts = errors.New("async: too many service restarts")
+
+// RestartStrategy selects which services a Supervisor restarts when one fails.
+type RestartStrategy int
+
+const (
+	// OneForOne restarts only the service that failed.
+	OneForOne RestartStrategy = iota
+	// OneForAll stops every other service, in reverse order, and restarts them
+	// all, in order, for services that depend on each other's state.
+	OneForAll
+)
+
+const (
+	defaultSupervisorPeriod   = time.Minute
+	defaultSupervisorDelay    = 100 * time.Millisecond
+	defaultSupervisorMaxDelay = 30 * time.Second
+)
+
+// SupervisorConfig configures a Supervisor. Zero fields select defaults.
+type SupervisorConfig struct {
+	// Strategy selects which services are restarted when one fails.
+	// The default is OneForOne.
+	Strategy RestartStrategy
+	// Backoff sets the delays between the restarts of a failing service, using
+	// its InitialDelay, MaxDelay, Multiplier and Jitter; its other fields are
+	// ignored. InitialDelay defaults to 100ms and MaxDelay to 30s.
+	Backoff RetryPolicy
+	// MaxRestarts is the maximum number of restarts allowed within Period; one
+	// more makes Run stop every service and return ErrTooManyRestarts.
+	// Zero or less means no limit.
+	MaxRestarts int
+	// Period is the window over which MaxRestarts is counted. A service that runs
+	// for a whole Period has its backoff reset. Defaults to one minute.
+	Period time.Duration
+	// ShutdownTimeout bounds how long the Supervisor waits for each service to
+	// return once its context is cancelled, after which it moves on and leaves
+	// the service running. Zero or less means no bound.
+	ShutdownTimeout time.Duration
+	// OnRestart, if set, is called with every failure and the delay before the
+	// service is restarted. The error is a *PanicError if the service panicked.
+	OnRestart func(name string, err error, delay time.Duration)
+	// Clock is the clock the backoff, Period and ShutdownTimeout are measured on.
+	// Defaults to the system clock.
+	Clock Clock
+}
+
+// Supervisor runs long-lived services, such as queue consumers, and restarts
+// them with a backoff when they fail, so that a transient error or a panic does
+// not silently stop them. A service that returns nil has completed and is not
+// restarted.
+//
+// Example:
+//
+//	sup := async.NewSupervisor(async.SupervisorConfig{
+//		MaxRestarts: 5,
+//		Period:      time.Minute,
+//		OnRestart: func(name string, err error, delay time.Duration) {
+//			log.Printf("service %s failed, restarting in %v: %v", name, delay, err)
+//		},
+//	})
+//	sup.Add("orders-consumer", consumeOrders)
+//	sup.Add("metrics-exporter", exportMetrics)
+//
+//	// Blocks until ctx is cancelled or the restart intensity is exceeded.
+//	if err := sup.Run(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+type Supervisor struct {
+	cfg SupervisorConfig
+
+	mu       sync.Mutex
+	services []*service
+	running  bool
+}
+
+// service is a supervised function and the state of its current run.
+type service struct {
+	name     string
+	fn       func(context.Context) error
+	backoff  *backoff
+	run      *serviceRun // nil while stopped
+	started  time.Time
+	finished bool // returned nil; never restarted
+}
+
+// serviceRun is one execution of a service.
+type serviceRun struct {
+	svc    *service
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewSupervisor returns a Supervisor without services.
+func NewSupervisor(cfg SupervisorConfig) *Supervisor {
+	if cfg.Period <= 0 {
+		cfg.Period = defaultSupervisorPeriod
+	}
+	if cfg.Backoff.InitialDelay <= 0 {
+		cfg.Backoff.InitialDelay = defaultSupervisorDelay
+	}
+	if cfg.Backoff.MaxDelay <= 0 {
+		cfg.Backoff.MaxDelay = defaultSupervisorMaxDelay
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = realClock{}
+	}
+	return &Supervisor{cfg: cfg}
+}
+
+// Add registers a service under a unique name. Services are started in the
+// order they are added and stopped in reverse order. Add must be called before Run.
+func (s *Supervisor) Add(name string, fn func(context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("async: cannot add service %q to a running supervisor", name)
+	}
+	for _, svc := range s.services {
+		if svc.name == name {
+			return fmt.Errorf("async: service %q is already supervised", name)
+		}
+	}
+	s.services = append(s.services, &service{name: name, fn: fn, backoff: newBackoff(s.cfg.Backoff)})
+	return nil
+}
+
+// Run starts every service and supervises them until ctx is done, all of them
+// have completed, or the restart intensity is exceeded. It then stops the
+// services still running in reverse order and returns nil, or an error wrapping
+// ErrTooManyRestarts and the last failure. Run may only be called once.
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return errors.New("async: supervisor is already running")
+	}
+	s.running = true
+	services := s.services
+	s.mu.Unlock()
+	if len(services) == 0 {
+		return nil
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+	exits := make(chan *serviceRun)
+	restarts := make(chan []*service)
+
+	// Services only see the cancellation of ctx through the supervisor, so that
+	// they can be stopped one at a time in reverse order.
+	base := context.WithoutCancel(ctx)
+	start := func(svc *service) {
+		runCtx, cancel := context.WithCancel(base)
+		r := &serviceRun{svc: svc, cancel: cancel, done: make(chan struct{})}
+		svc.run = r
+		svc.started = s.cfg.Clock.Now()
+		go func() {
+			r.err = catch(func() error { return svc.fn(runCtx) }, nil)
+			cancel()
+			close(r.done)
+			select {
+			case exits <- r:
+			case <-quit:
+			}
+		}()
+	}
+
+	for _, svc := range services {
+		start(svc)
+	}
+
+	var restartTimes []time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			s.stop(services)
+			return nil
+
+		case r := <-exits:
+			svc := r.svc
+			if svc.run != r {
+				continue // stopped by the supervisor
+			}
+			svc.run = nil
+			if r.err == nil {
+				svc.finished = true
+				if s.allFinished(services) {
+					return nil
+				}
+				continue
+			}
+
+			now := s.cfg.Clock.Now()
+			if now.Sub(svc.started) >= s.cfg.Period {
+				svc.backoff = newBackoff(s.cfg.Backoff)
+			}
+			restartTimes = append(restartTimes, now)
+			for len(restartTimes) > 0 && now.Sub(restartTimes[0]) > s.cfg.Period {
+				restartTimes = restartTimes[1:]
+			}
+			if s.cfg.MaxRestarts > 0 && len(restartTimes) > s.cfg.MaxRestarts {
+				s.stop(services)
+				return fmt.Errorf("%w: service %q: %w", ErrTooManyRestarts, svc.name, r.err)
+			}
+
+			delay := svc.backoff.next()
+			if s.cfg.OnRestart != nil {
+				s.cfg.OnRestart(svc.name, r.err, delay)
+			}
+
+			group := []*service{svc}
+			if s.cfg.Strategy == OneForAll {
+				s.stop(services)
+				group = services
+			}
+			s.cfg.Clock.AfterFunc(delay, func() {
+				select {
+				case restarts <- group:
+				case <-quit:
+				}
+			})
+
+		case group := <-restarts:
+			if ctx.Err() != nil {
+				continue
+			}
+			for _, svc := range group {
+				if svc.run == nil && !svc.finished {
+					start(svc)
+				}
+			}
+		}
+	}
+}
+
+// stop cancels the running services in reverse order, waiting for each one to
+// return before stopping the next.
+func (s *Supervisor) stop(services []*service) {
+	for i := len(services) - 1; i >= 0; i-- {
+		r := services[i].run
+		if r == nil {
+			continue
+		}
+		services[i].run = nil
+		r.cancel()
+
+		if s.cfg.ShutdownTimeout <= 0 {
+			<-r.done
+			continue
+		}
+		timer := s.cfg.Clock.NewTimer(s.cfg.ShutdownTimeout)
+		select {
+		case <-r.done:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// allFinished reports whether every service has completed.
+func (s *Supervisor) allFinished(services []*service) bool {
+	for _, svc := range services {
+		if !svc.finished {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	fastBackoff := RetryPolicy{InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	t.Run("restarts failing services", func(t *testing.T) {
+		var restarts int32
+		sup := NewSupervisor(SupervisorConfig{
+			Backoff:   fastBackoff,
+			OnRestart: func(string, error, time.Duration) { atomic.AddInt32(&restarts, 1) },
+		})
+
+		var runs int32
+		sup.Add("flaky", func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) < 3 {
+				return errors.New("crashed")
+			}
+			<-ctx.Done()
+			return nil
+		})
+		var panics int32
+		sup.Add("panicky", func(ctx context.Context) error {
+			if atomic.AddInt32(&panics, 1) == 1 {
+				panic("boom")
+			}
+			<-ctx.Done()
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := sup.Run(ctx); err != nil {
+			t.Fatalf("Run() = %v, want nil", err)
+		}
+		if runs != 3 || panics != 2 {
+			t.Errorf("runs = %d and %d, want 3 and 2", runs, panics)
+		}
+		if restarts != 3 {
+			t.Errorf("OnRestart called %d times, want 3", restarts)
+		}
+	})
+
+	t.Run("completed services are not restarted", func(t *testing.T) {
+		sup := NewSupervisor(SupervisorConfig{Backoff: fastBackoff})
+		var runs int32
+		sup.Add("once", func(context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+
+		if err := sup.Run(context.Background()); err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+		if runs != 1 {
+			t.Errorf("runs = %d, want 1", runs)
+		}
+	})
+
+	t.Run("restart intensity", func(t *testing.T) {
+		errCrash := errors.New("crash")
+		sup := NewSupervisor(SupervisorConfig{Backoff: fastBackoff, MaxRestarts: 3, Period: time.Second})
+		var runs int32
+		sup.Add("broken", func(context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return errCrash
+		})
+		stopped := make(chan struct{})
+		sup.Add("healthy", func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		})
+
+		err := sup.Run(context.Background())
+		if !errors.Is(err, ErrTooManyRestarts) || !errors.Is(err, errCrash) {
+			t.Errorf("Run() = %v, want ErrTooManyRestarts wrapping the failure", err)
+		}
+		if runs != 4 {
+			t.Errorf("runs = %d, want 4", runs)
+		}
+		select {
+		case <-stopped:
+		default:
+			t.Error("healthy service was not stopped")
+		}
+	})
+
+	t.Run("one for all", func(t *testing.T) {
+		sup := NewSupervisor(SupervisorConfig{Strategy: OneForAll, Backoff: fastBackoff})
+		var mu sync.Mutex
+		var events []string
+		record := func(e string) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		}
+
+		var dbRuns int32
+		sup.Add("db", func(ctx context.Context) error {
+			record("db start")
+			<-ctx.Done()
+			record("db stop")
+			return nil
+		})
+		sup.Add("worker", func(ctx context.Context) error {
+			record("worker start")
+			if atomic.AddInt32(&dbRuns, 1) == 1 {
+				time.Sleep(5 * time.Millisecond)
+				return errors.New("crash")
+			}
+			<-ctx.Done()
+			record("worker stop")
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		sup.Run(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		// Services start concurrently, so only the stops are strictly ordered.
+		want := []string{"start", "start", "db stop", "start", "start", "worker stop", "db stop"}
+		if len(events) != len(want) {
+			t.Fatalf("events = %v, want %v", events, want)
+		}
+		for i, e := range events {
+			if e != want[i] && !(want[i] == "start" && (e == "db start" || e == "worker start")) {
+				t.Fatalf("events = %v, want the crash to restart both services", events)
+			}
+		}
+	})
+
+	t.Run("stops services in reverse order", func(t *testing.T) {
+		sup := NewSupervisor(SupervisorConfig{})
+		var mu sync.Mutex
+		var stops []string
+		for _, name := range []string{"a", "b", "c"} {
+			name := name
+			sup.Add(name, func(ctx context.Context) error {
+				<-ctx.Done()
+				mu.Lock()
+				stops = append(stops, name)
+				mu.Unlock()
+				return nil
+			})
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		sup.Run(ctx)
+
+		if len(stops) != 3 || stops[0] != "c" || stops[1] != "b" || stops[2] != "a" {
+			t.Errorf("stop order = %v, want [c b a]", stops)
+		}
+	})
+
+	t.Run("shutdown timeout", func(t *testing.T) {
+		sup := NewSupervisor(SupervisorConfig{ShutdownTimeout: 10 * time.Millisecond})
+		release := make(chan struct{})
+		defer close(release)
+		sup.Add("stubborn", func(context.Context) error {
+			<-release
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			sup.Run(ctx)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run() did not return after the shutdown timeout")
+		}
+	})
+
+	t.Run("Add errors", func(t *testing.T) {
+		sup := NewSupervisor(SupervisorConfig{})
+		sup.Add("a", func(context.Context) error { return nil })
+		if err := sup.Add("a", func(context.Context) error { return nil }); err == nil {
+			t.Error("Add() with a duplicate name = nil, want error")
+		}
+		sup.Run(context.Background())
+		if err := sup.Add("b", func(context.Context) error { return nil }); err == nil {
+			t.Error("Add() after Run = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned by Timeout, TimeoutCtx and TimeoutValue when the
+// function does not complete in time. The errors they return carry the timeout
+// duration but match ErrTimeout with errors.Is, and they also match
+// context.DeadlineExceeded, so code that already checks for deadlines keeps working.
+//
+// Example:
+//
+//	err := async.TimeoutCtx(ctx, query, time.Second)
+//	if errors.Is(err, async.ErrTimeout) {
+//		metrics.Timeouts.Inc()
+//	}
+var ErrTimeout error = &timeoutError{}
+
+// timeoutError is the error returned when a timeout expires.
+type timeoutError struct {
+	after time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	if e.after <= 0 {
+		return "async: operation timed out"
+	}
+	return fmt.Sprintf("async: operation timed out after %v", e.after)
+}
+
+// Is makes every timeoutError match ErrTimeout and context.DeadlineExceeded.
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimeout || target == context.DeadlineExceeded
+}
+
+// Timeout reports true, like the errors of the net package, for code that checks
+// for timeouts through an interface.
+func (e *timeoutError) Timeout() bool { return true }
+
+// Timeout wraps a function with a timeout mechanism.
+// Returns an error matching ErrTimeout if the function doesn't complete within the
+// specified duration. f keeps running in the background after that, so prefer
+// TimeoutCtx for functions that can be cancelled.
+// A panic in f is recovered and returned as a *PanicError.
+//
+// Example:
+//
+//	err := async.Timeout(func() error {
+//		time.Sleep(200 * time.Millisecond)
+//		return nil
+//	}, 100*time.Millisecond)
+//
+//	if err != nil {
+//		fmt.Printf("Function timed out: %v\n", err)
+//	}
+func Timeout(f func() error, timeout time.Duration, opts ...Option) error {
+	return TimeoutCtx(context.Background(), func(context.Context) error {
+		return f()
+	}, timeout, opts...)
+}
+
+// TimeoutCtx calls f with a context that is cancelled once timeout has passed or
+// ctx is done, and returns f's error, or an error matching ErrTimeout if timeout
+// passes first, or ctx's error if ctx is done first. It returns as soon as the
+// context is cancelled, without waiting for f, which is expected to notice the
+// cancellation and return.
+// A panic in f is recovered and returned as a *PanicError.
+//
+// Example:
+//
+//	err := async.TimeoutCtx(ctx, func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	}, 2*time.Second)
+func TimeoutCtx(ctx context.Context, f func(context.Context) error, timeout time.Duration, opts ...Option) error {
+	_, err := TimeoutValue(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, f(ctx)
+	}, timeout, opts...)
+	return err
+}
+
+// TimeoutValue is like TimeoutCtx for functions that return a value.
+//
+// The timeout is measured on the clock set with WithClock. With a clock other
+// than the system clock, the context passed to f has no deadline and is only
+// cancelled once the clock has advanced by timeout.
+//
+// Example:
+//
+//	user, err := async.TimeoutValue(ctx, func(ctx context.Context) (User, error) {
+//		return client.GetUser(ctx, id)
+//	}, 500*time.Millisecond)
+//	if errors.Is(err, context.DeadlineExceeded) {
+//		return fallbackUser(id), nil
+//	}
+func TimeoutValue[T any](ctx context.Context, f func(context.Context) (T, error), timeout time.Duration, opts ...Option) (T, error) {
+	o := applyOptions(opts)
+	expired := &timeoutError{after: timeout}
+	ctx, cancel := contextWithTimeout(ctx, o.clock, timeout, expired)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		r.err = catch(func() (err error) {
+			r.value, err = f(ctx)
+			return err
+		}, o.panicHandler)
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+	}
+
+	// Prefer a successful result that raced with the deadline.
+	select {
+	case r := <-done:
+		if r.err == nil {
+			return r.value, nil
+		}
+	default:
+	}
+
+	var zero T
+	if cause := context.Cause(ctx); cause == expired {
+		return zero, expired
+	}
+	return zero, ctx.Err()
+}
@@ -0,0 +1,173 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Run("function completes within timeout", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		err := Timeout(func() error {
+			clock.Advance(50 * time.Millisecond)
+			return nil
+		}, 100*time.Millisecond, WithClock(clock))
+
+		if err != nil {
+			t.Errorf("Timeout() should not error for function that completes in time, got: %v", err)
+		}
+	})
+
+	t.Run("function times out", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		release := make(chan struct{})
+		defer close(release)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- Timeout(func() error {
+				<-release
+				return nil
+			}, 100*time.Millisecond, WithClock(clock))
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(100 * time.Millisecond)
+
+		err := <-errc
+		if err == nil {
+			t.Error("Timeout() should error for function that takes too long")
+		}
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Timeout() = %v, want ErrTimeout and context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("function returns error", func(t *testing.T) {
+		expectedErr := fmt.Errorf("test error")
+		err := Timeout(func() error {
+			return expectedErr
+		}, 100*time.Millisecond)
+
+		if err != expectedErr {
+			t.Errorf("Timeout() should return function error, got: %v, want: %v", err, expectedErr)
+		}
+	})
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	err := Timeout(func() error {
+		panic("boom")
+	}, 100*time.Millisecond)
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("Timeout() = %v, want *PanicError", err)
+	}
+}
+
+func TestTimeoutCtx(t *testing.T) {
+	t.Run("expires on the clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- TimeoutCtx(context.Background(), func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}, time.Hour, WithClock(clock))
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+		if err := <-errc; !errors.Is(err, ErrTimeout) {
+			t.Errorf("TimeoutCtx() error = %v, want ErrTimeout", err)
+		}
+	})
+
+	t.Run("cancels the function on expiry", func(t *testing.T) {
+		stopped := make(chan error, 1)
+		err := TimeoutCtx(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return ctx.Err()
+		}, 20*time.Millisecond)
+
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("TimeoutCtx() = %v, want ErrTimeout and context.DeadlineExceeded", err)
+		}
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Error("function context was not cancelled")
+		}
+	})
+
+	t.Run("returns the function error", func(t *testing.T) {
+		want := errors.New("failed")
+		err := TimeoutCtx(context.Background(), func(context.Context) error { return want }, time.Second)
+		if err != want {
+			t.Errorf("TimeoutCtx() = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("parent cancellation is not a timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := TimeoutCtx(ctx, func(ctx context.Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}, time.Hour)
+		if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+			t.Errorf("TimeoutCtx() = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("returns without waiting for the function", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		release := make(chan struct{})
+		defer close(release)
+		errc := make(chan error, 1)
+		go func() {
+			// The function ignores its context and only returns once released.
+			errc <- TimeoutCtx(context.Background(), func(context.Context) error {
+				<-release
+				return nil
+			}, 20*time.Millisecond, WithClock(clock))
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(20 * time.Millisecond)
+
+		if err := <-errc; !errors.Is(err, ErrTimeout) {
+			t.Errorf("TimeoutCtx() = %v, want ErrTimeout", err)
+		}
+	})
+}
+
+func TestTimeoutValue(t *testing.T) {
+	got, err := TimeoutValue(context.Background(), func(context.Context) (int, error) {
+		return 42, nil
+	}, time.Second)
+	if err != nil || got != 42 {
+		t.Errorf("TimeoutValue() = %d, %v, want 42, nil", got, err)
+	}
+
+	got, err = TimeoutValue(context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 1, ctx.Err()
+	}, 10*time.Millisecond)
+	if got != 0 || !errors.Is(err, ErrTimeout) {
+		t.Errorf("TimeoutValue() = %d, %v, want 0, ErrTimeout", got, err)
+	}
+	if want := "async: operation timed out after 10ms"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+
+	_, err = TimeoutValue(context.Background(), func(context.Context) (int, error) {
+		panic("boom")
+	}, time.Second)
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Errorf("TimeoutValue() = %v, want *PanicError", err)
+	}
+}